@@ -0,0 +1,256 @@
+// Command arena plays matches between registered bots and reports their
+// relative strength.
+//
+//	go run ./cmd/arena -a strong -b mcts -games 200 -parallel 4 -random-plies 4
+//
+// External engines can join with -engine name=command (repeatable), and
+// -rows, -columns and -connect play on other boards.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"connect4/game"
+)
+
+// engineFlags collects repeated -engine flags
+type engineFlags []string
+
+func (e *engineFlags) String() string     { return strings.Join(*e, ",") }
+func (e *engineFlags) Set(v string) error { *e = append(*e, v); return nil }
+
+// gameResult is the outcome of one arena game, from bot A's point of view
+type gameResult struct {
+	score     float64 // 1 win, 0.5 draw, 0 loss
+	moveTimes [2]time.Duration
+	moves     [2]int
+}
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run plays the match. It returns errors instead of exiting so the engines
+// are always closed.
+func run() error {
+	var engines engineFlags
+	nameA := flag.String("a", "strong", "first bot")
+	nameB := flag.String("b", "casual", "second bot")
+	games := flag.Int("games", 100, "number of games; A and B alternate moving first")
+	parallel := flag.Int("parallel", runtime.NumCPU(), "games played at once")
+	moveTime := flag.Duration("movetime", 100*time.Millisecond, "thinking time per move (beginner and casual always move at once)")
+	randomPlies := flag.Int("random-plies", 0, "random opening moves before the bots take over")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "seed for random openings")
+	rules := game.ClassicRules
+	flag.IntVar(&rules.Rows, "rows", rules.Rows, "board rows")
+	flag.IntVar(&rules.Columns, "columns", rules.Columns, "board columns")
+	flag.IntVar(&rules.Connect, "connect", rules.Connect, "discs in a row needed to win")
+	flag.Var(&engines, "engine", "register an external engine as name=command args (repeatable)")
+	flag.Parse()
+
+	for _, spec := range engines {
+		engine, err := game.ParseEngineSpec(spec)
+		if err != nil {
+			return err
+		}
+		game.RegisterBot(engine)
+		defer engine.Close()
+	}
+
+	botA, okA := game.LookupBot(*nameA)
+	botB, okB := game.LookupBot(*nameB)
+	if !okA || !okB {
+		return fmt.Errorf("unknown bot; registered bots: %s", strings.Join(game.BotNames(), ", "))
+	}
+	if err := rules.Validate(); err != nil {
+		return err
+	}
+	// An opening that fills the board leaves no game to play
+	if cells := rules.Rows * rules.Columns; *randomPlies < 0 || *randomPlies >= cells {
+		return fmt.Errorf("-random-plies must be between 0 and %d on a %s board", cells-1, rules)
+	}
+	if *parallel < 1 {
+		*parallel = 1
+	}
+
+	// Each opening is played twice, once with each bot moving first
+	rng := rand.New(rand.NewSource(*seed))
+	openings := make([]game.Position, (*games+1)/2)
+	for i := range openings {
+		openings[i] = randomOpening(rng, rules, *randomPlies)
+	}
+
+	log.Printf("%s vs %s: %d games, %s, %d in parallel, %v per move, %d random plies (seed %d)",
+		botA.Name(), botB.Name(), *games, rules, *parallel, *moveTime, *randomPlies, *seed)
+
+	jobs := make(chan int)
+	results := make(chan gameResult)
+	var wg sync.WaitGroup
+	for w := 0; w < *parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				aFirst := i%2 == 0
+				results <- playGame(openings[i/2], botA, botB, aFirst, *moveTime)
+			}
+		}()
+	}
+	go func() {
+		for i := 0; i < *games; i++ {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	var wins, draws, losses int
+	var totalTime [2]time.Duration
+	var totalMoves [2]int
+	played := 0
+	for r := range results {
+		switch r.score {
+		case 1:
+			wins++
+		case 0.5:
+			draws++
+		default:
+			losses++
+		}
+		for i := 0; i < 2; i++ {
+			totalTime[i] += r.moveTimes[i]
+			totalMoves[i] += r.moves[i]
+		}
+		played++
+		if played%10 == 0 || played == *games {
+			log.Printf("%d/%d games: +%d =%d -%d", played, *games, wins, draws, losses)
+		}
+	}
+
+	elo, low, high := eloDifference(wins, draws, losses)
+	fmt.Printf("\n%s vs %s after %d games\n", botA.Name(), botB.Name(), played)
+	fmt.Printf("  %s: %d wins, %d draws, %d losses (score %.1f%%)\n",
+		botA.Name(), wins, draws, losses, 100*(float64(wins)+0.5*float64(draws))/float64(played))
+	fmt.Printf("  Elo difference: %+.0f (95%% CI %+.0f to %+.0f)\n", elo, low, high)
+	fmt.Printf("  Average move time: %s %v, %s %v\n",
+		botA.Name(), averageTime(totalTime[0], totalMoves[0]),
+		botB.Name(), averageTime(totalTime[1], totalMoves[1]))
+	return nil
+}
+
+// randomOpening plays plies random moves that don't end the game
+func randomOpening(rng *rand.Rand, rules game.Rules, plies int) game.Position {
+	for {
+		p := game.NewPositionWithRules(rules)
+		ok := true
+		for p.Moves() < plies && ok {
+			var cols []int
+			for c := 0; c < p.Columns(); c++ {
+				if p.CanPlay(c) && !p.IsWinningMove(c) {
+					cols = append(cols, c)
+				}
+			}
+			if len(cols) == 0 {
+				ok = false
+				break
+			}
+			p.Play(cols[rng.Intn(len(cols))])
+		}
+		if ok && !p.CanWinNext() {
+			return p
+		}
+	}
+}
+
+// playGame plays one game from start and scores it for bot A
+func playGame(start game.Position, botA, botB game.Bot, aFirst bool, moveTime time.Duration) gameResult {
+	var r gameResult
+	p := start
+
+	// aTurn is the Position turn (1 or 2) on which A moves
+	aTurn := start.Turn()
+	if !aFirst {
+		aTurn = 3 - aTurn
+	}
+
+	for {
+		side := 1 // index into r.moveTimes: 0 for A, 1 for B
+		bot := botB
+		if p.Turn() == aTurn {
+			side = 0
+			bot = botA
+		}
+
+		begin := time.Now()
+		col := bot.ChooseMove(p, begin.Add(moveTime))
+		r.moveTimes[side] += time.Since(begin)
+		r.moves[side]++
+
+		if !p.CanPlay(col) {
+			log.Printf("%s played illegal column %d, forfeiting", bot.Name(), col)
+			if side == 0 {
+				r.score = 0
+			} else {
+				r.score = 1
+			}
+			return r
+		}
+		if p.IsWinningMove(col) {
+			if side == 0 {
+				r.score = 1
+			} else {
+				r.score = 0
+			}
+			return r
+		}
+		p.Play(col)
+		if p.IsFull() {
+			r.score = 0.5
+			return r
+		}
+	}
+}
+
+// eloDifference returns the Elo difference implied by a match result and
+// its 95% confidence interval
+func eloDifference(wins, draws, losses int) (float64, float64, float64) {
+	n := float64(wins + draws + losses)
+	if n == 0 {
+		return 0, 0, 0
+	}
+	score := (float64(wins) + 0.5*float64(draws)) / n
+
+	variance := (float64(wins)*math.Pow(1-score, 2) +
+		float64(draws)*math.Pow(0.5-score, 2) +
+		float64(losses)*math.Pow(score, 2)) / n
+	margin := 1.96 * math.Sqrt(variance/n)
+
+	return scoreToElo(score), scoreToElo(score - margin), scoreToElo(score + margin)
+}
+
+// scoreToElo converts an expected score into an Elo difference, capped so
+// that a perfect score doesn't give infinity
+func scoreToElo(score float64) float64 {
+	const limit = 0.999
+	score = math.Max(1-limit, math.Min(limit, score))
+	return -400 * math.Log10(1/score-1)
+}
+
+// averageTime divides total by moves, rounded for display
+func averageTime(total time.Duration, moves int) time.Duration {
+	if moves == 0 {
+		return 0
+	}
+	return (total / time.Duration(moves)).Round(time.Microsecond)
+}
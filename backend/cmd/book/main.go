@@ -0,0 +1,142 @@
+// Command book regenerates game/opening_book.txt by solving every position
+// up to a given number of plies.
+//
+//	go run ./cmd/book -plies 2 -out game/opening_book.txt
+//
+// Early positions take a long time to solve; -from lets the work be split
+// into runs, with the output files concatenated afterwards. The solver
+// looks positions up in the embedded book as it searches, so build a deep
+// book from the bottom up: solve the deepest ply first, append it to
+// game/opening_book.txt, and the shallower plies then come from the book
+// almost at once:
+//
+//	go run ./cmd/book -from 8 -plies 8 -workers 16 -out ply8.txt
+//	cat ply8.txt >> game/opening_book.txt
+//	go run ./cmd/book -from 2 -plies 7 -out ply2-7.txt
+//
+// The deepest ply is the expensive part: the ~90,000 positions with 8 discs
+// average about 2s each on one core.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"connect4/game"
+)
+
+type entry struct {
+	moves string
+	score int
+}
+
+func main() {
+	from := flag.Int("from", 0, "skip positions with fewer discs than this")
+	plies := flag.Int("plies", 2, "solve every position with up to this many discs")
+	workers := flag.Int("workers", 4, "number of positions solved in parallel")
+	out := flag.String("out", "game/opening_book.txt", "output file")
+	flag.Parse()
+
+	sequences := collectPositions(*from, *plies)
+	log.Printf("Solving %d positions with %d workers", len(sequences), *workers)
+
+	jobs := make(chan string)
+	results := make(chan entry)
+	var wg sync.WaitGroup
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			solver := game.NewSolver()
+			for moves := range jobs {
+				p, err := game.ParseMoves(moves)
+				if err != nil {
+					log.Fatalf("%s: %v", moves, err)
+				}
+				start := time.Now()
+				r, _ := solver.Solve(p, time.Time{})
+				log.Printf("%-8s score %3d (%s)", moves, r.Score, time.Since(start).Round(time.Millisecond))
+				results <- entry{moves: moves, score: r.Score}
+			}
+		}()
+	}
+	go func() {
+		for _, moves := range sequences {
+			jobs <- moves
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	var entries []entry
+	for e := range results {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if len(entries[i].moves) != len(entries[j].moves) {
+			return len(entries[i].moves) < len(entries[j].moves)
+		}
+		return entries[i].moves < entries[j].moves
+	})
+
+	var b strings.Builder
+	b.WriteString("# Connect Four opening book: <moves> <score>\n")
+	b.WriteString("# moves are 1-based columns from the empty board (\"-\" is the empty board),\n")
+	b.WriteString("# score is the solver score for the player to move. Generated by cmd/book.\n")
+	for _, e := range entries {
+		moves := e.moves
+		if moves == "" {
+			moves = "-"
+		}
+		fmt.Fprintf(&b, "%s %d\n", moves, e.score)
+	}
+	if err := os.WriteFile(*out, []byte(b.String()), 0644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Wrote %d entries to %s", len(entries), *out)
+}
+
+// collectPositions returns one move sequence per distinct position (up to
+// mirroring) with from..plies discs, skipping positions where the player
+// to move can win at once since the solver answers those instantly
+func collectPositions(from, plies int) []string {
+	seen := make(map[uint64]bool)
+	var sequences []string
+
+	var walk func(p game.Position, moves string)
+	walk = func(p game.Position, moves string) {
+		key := p.CanonicalKey()
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+
+		if p.CanWinNext() {
+			return
+		}
+		if len(moves) >= from {
+			sequences = append(sequences, moves)
+		}
+		if len(moves) == plies {
+			return
+		}
+		for c := 0; c < 7; c++ {
+			if !p.CanPlay(c) {
+				continue
+			}
+			next := p
+			next.Play(c)
+			walk(next, moves+string(rune('1'+c)))
+		}
+	}
+	walk(game.NewPosition(), "")
+
+	return sequences
+}
@@ -2,15 +2,23 @@ package db
 
 import (
 	"context"
+	"errors"
+	"log"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func SaveGameResult(player1, player2 string, winner int) error {
+// SaveGameResult records a finished game. reason says how it ended, e.g.
+// "connect4" or "resignation". Rated games also update both players'
+// ratings in the same transaction, and the changes are returned by player
+// (nil for unrated games). Without a replica set there are no
+// transactions, and the writes are made one after the other.
+func SaveGameResult(player1, player2 string, winner int, reason string, rated bool) (map[string]RatingChange, error) {
 	if Client == nil {
 		// MongoDB not connected, silently skip saving
-		return nil
+		return nil, nil
 	}
 
 	collection := Client.Database("connect4").Collection("games")
@@ -22,9 +30,59 @@ func SaveGameResult(player1, player2 string, winner int) error {
 		"player2":   player2,
 		"winner":    winner,
 		"isDraw":    winner == 0,
+		"reason":    reason,
+		"rated":     rated,
 		"createdAt": time.Now(),
 	}
 
-	_, err := collection.InsertOne(context.Background(), doc)
-	return err
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if !rated {
+		_, err := collection.InsertOne(ctx, doc)
+		return nil, err
+	}
+
+	// The game and both rating updates are saved together or not at all
+	changes, err := saveRatedGame(ctx, collection, doc, player1, player2, winner)
+	if transactionsUnsupported(err) {
+		// A standalone mongod (e.g. a local install) has no transactions:
+		// write one after the other instead
+		log.Println("MongoDB has no transactions (not a replica set), saving the rated game without one")
+		if _, err := collection.InsertOne(ctx, doc); err != nil {
+			return nil, err
+		}
+		return rateGame(ctx, player1, player2, winner)
+	}
+	return changes, err
+}
+
+// saveRatedGame inserts doc and rates the game in one transaction
+func saveRatedGame(ctx context.Context, collection *mongo.Collection, doc bson.M, player1, player2 string, winner int) (map[string]RatingChange, error) {
+	session, err := Client.StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+
+	var changes map[string]RatingChange
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		if _, err := collection.InsertOne(sc, doc); err != nil {
+			return nil, err
+		}
+		c, err := rateGame(sc, player1, player2, winner)
+		changes = c
+		return nil, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// transactionsUnsupported reports whether err is the server refusing a
+// transaction because it isn't a replica set member or mongos
+func transactionsUnsupported(err error) bool {
+	var se mongo.ServerError
+	return errors.As(err, &se) && se.HasErrorCodeWithMessage(20, "Transaction numbers")
 }
@@ -0,0 +1,105 @@
+package db
+
+import "math"
+
+// Glicko is a player's Glicko-2 rating (Glickman, "Example of the Glicko-2
+// system"). Deviation is how uncertain the rating is and Volatility how
+// erratic the player's results are.
+type Glicko struct {
+	Rating     float64 `bson:"rating" json:"rating"`
+	Deviation  float64 `bson:"deviation" json:"deviation"`
+	Volatility float64 `bson:"volatility" json:"volatility"`
+}
+
+// NewGlicko is the rating of a player who hasn't played a rated game
+var NewGlicko = Glicko{Rating: 1500, Deviation: 350, Volatility: 0.06}
+
+// Glicko-2 system constants
+const (
+	glickoScale = 173.7178 // converts between the Glicko and Glicko-2 scales
+	glickoTau   = 0.5      // how much volatility may change, 0.3 to 1.2
+	glickoEps   = 0.000001 // convergence tolerance for the volatility
+)
+
+// Outcome is one game against an opponent: Score is 1 for a win, 0.5 for a
+// draw and 0 for a loss
+type Outcome struct {
+	Opponent Glicko
+	Score    float64
+}
+
+// Update returns g after a rating period with the given games. With no
+// games only the deviation grows.
+func (g Glicko) Update(outcomes []Outcome) Glicko {
+	mu := (g.Rating - 1500) / glickoScale
+	phi := g.Deviation / glickoScale
+
+	if len(outcomes) == 0 {
+		phiStar := math.Sqrt(phi*phi + g.Volatility*g.Volatility)
+		return Glicko{Rating: g.Rating, Deviation: phiStar * glickoScale, Volatility: g.Volatility}
+	}
+
+	// Estimated variance v and improvement delta from the games
+	var invV, sum float64
+	for _, o := range outcomes {
+		muJ := (o.Opponent.Rating - 1500) / glickoScale
+		gJ := glickoG(o.Opponent.Deviation / glickoScale)
+		e := 1 / (1 + math.Exp(-gJ*(mu-muJ)))
+		invV += gJ * gJ * e * (1 - e)
+		sum += gJ * (o.Score - e)
+	}
+	v := 1 / invV
+	delta := v * sum
+
+	sigma := glickoVolatility(phi, g.Volatility, v, delta)
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phiNew := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	muNew := mu + phiNew*phiNew*sum
+
+	return Glicko{
+		Rating:     muNew*glickoScale + 1500,
+		Deviation:  phiNew * glickoScale,
+		Volatility: sigma,
+	}
+}
+
+// glickoG weighs a game by how certain the opponent's rating is
+func glickoG(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+// glickoVolatility finds the new volatility with the Illinois algorithm
+// (step 5 of Glickman's paper)
+func glickoVolatility(phi, sigma, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-d)/(2*d*d) - (x-a)/(glickoTau*glickoTau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*glickoTau) < 0 {
+			k++
+		}
+		B = a - k*glickoTau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > glickoEps {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGlickoUpdateGlickmanExample(t *testing.T) {
+	// The worked example in Glickman's "Example of the Glicko-2 system"
+	player := Glicko{Rating: 1500, Deviation: 200, Volatility: 0.06}
+	got := player.Update([]Outcome{
+		{Opponent: Glicko{Rating: 1400, Deviation: 30}, Score: 1},
+		{Opponent: Glicko{Rating: 1550, Deviation: 100}, Score: 0},
+		{Opponent: Glicko{Rating: 1700, Deviation: 300}, Score: 0},
+	})
+	for _, tc := range []struct {
+		name      string
+		got, want float64
+		tolerance float64
+	}{
+		{"rating", got.Rating, 1464.06, 0.01},
+		{"deviation", got.Deviation, 151.52, 0.01},
+		{"volatility", got.Volatility, 0.05999, 0.00001},
+	} {
+		if math.Abs(tc.got-tc.want) > tc.tolerance {
+			t.Errorf("%s = %v, want %v", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestGlickoUpdateWithoutGames(t *testing.T) {
+	// Only the deviation grows: sqrt(phi² + sigma²) on the Glicko-2 scale
+	player := Glicko{Rating: 1500, Deviation: 200, Volatility: 0.06}
+	got := player.Update(nil)
+	want := math.Sqrt(math.Pow(200/glickoScale, 2)+0.06*0.06) * glickoScale
+	if got.Rating != 1500 || got.Volatility != 0.06 || math.Abs(got.Deviation-want) > 1e-9 {
+		t.Errorf("Update(nil) = %+v, want deviation %v", got, want)
+	}
+}
+
+func TestResultScore(t *testing.T) {
+	tests := []struct {
+		winner int
+		want   float64
+	}{
+		{1, 1},
+		{2, 0},
+		{0, 0.5},
+	}
+	for _, tc := range tests {
+		score := resultScore(tc.winner)
+		if score != tc.want {
+			t.Errorf("resultScore(%d) = %v, want %v", tc.winner, score, tc.want)
+		}
+		// The players' ratings move in opposite directions, and not at all
+		// between equals who draw
+		p1 := NewGlicko.Update([]Outcome{{Opponent: NewGlicko, Score: score}})
+		p2 := NewGlicko.Update([]Outcome{{Opponent: NewGlicko, Score: 1 - score}})
+		switch tc.winner {
+		case 1:
+			if p1.Rating <= 1500 || p2.Rating >= 1500 {
+				t.Errorf("winner 1: ratings %v and %v", p1.Rating, p2.Rating)
+			}
+		case 2:
+			if p1.Rating >= 1500 || p2.Rating <= 1500 {
+				t.Errorf("winner 2: ratings %v and %v", p1.Rating, p2.Rating)
+			}
+		default:
+			if math.Abs(p1.Rating-1500) > 1e-9 || math.Abs(p2.Rating-1500) > 1e-9 {
+				t.Errorf("draw: ratings %v and %v", p1.Rating, p2.Rating)
+			}
+		}
+	}
+}
@@ -4,57 +4,34 @@ import (
 	"context"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-type LeaderboardEntry struct {
-	Player string `bson:"_id" json:"player"`
-	Wins   int    `bson:"wins" json:"wins"`
-}
+// DefaultMinGames is how many rated games a player needs to appear on the
+// leaderboard unless the request says otherwise
+const DefaultMinGames = 5
+
+// leaderboardSize is how many players GetLeaderboard returns at most
+const leaderboardSize = 100
 
-func GetLeaderboard() ([]LeaderboardEntry, error) {
+// GetLeaderboard returns the highest-rated players with at least minGames
+// rated games, best first
+func GetLeaderboard(minGames int) ([]PlayerRating, error) {
 	if Client == nil {
 		// MongoDB not connected, return empty leaderboard
-		return []LeaderboardEntry{}, nil
-	}
-
-	collection := Client.Database("connect4").Collection("games")
-
-	pipeline := []bson.M{
-		// Filter out draws (winner == 0)
-		{
-			"$match": bson.M{
-				"winner": bson.M{"$ne": 0},
-			},
-		},
-		{
-			"$project": bson.M{
-				"winnerPlayer": bson.M{
-					"$cond": []interface{}{
-						bson.M{"$eq": []interface{}{"$winner", 1}},
-						"$player1",
-						"$player2",
-					},
-				},
-			},
-		},
-		{
-			"$group": bson.M{
-				"_id":  "$winnerPlayer",
-				"wins": bson.M{"$sum": 1},
-			},
-		},
-		{
-			"$sort": bson.M{"wins": -1},
-		},
+		return []PlayerRating{}, nil
 	}
 
-	cursor, err := collection.Aggregate(context.Background(), pipeline)
+	opts := options.Find().
+		SetSort(bson.D{{Key: "rating", Value: -1}, {Key: "games", Value: -1}}).
+		SetLimit(leaderboardSize)
+	cursor, err := ratings().Find(context.Background(), bson.M{"games": bson.M{"$gte": minGames}}, opts)
 	if err != nil {
 		return nil, err
 	}
 	defer cursor.Close(context.Background())
 
-	var results []LeaderboardEntry
+	results := []PlayerRating{}
 	if err := cursor.All(context.Background(), &results); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PlayerRating is a player's document in the ratings collection
+type PlayerRating struct {
+	Player string `bson:"_id" json:"player"`
+	Glicko `bson:",inline"`
+	Games  int `bson:"games" json:"games"`
+	Wins   int `bson:"wins" json:"wins"`
+	Losses int `bson:"losses" json:"losses"`
+	Draws  int `bson:"draws" json:"draws"`
+}
+
+// RatingChange is how one game moved a player's rating
+type RatingChange struct {
+	Rating float64 `json:"rating"` // new rating
+	Delta  float64 `json:"delta"`
+}
+
+// ratings is the collection of PlayerRating documents
+func ratings() *mongo.Collection {
+	return Client.Database("connect4").Collection("ratings")
+}
+
+// GetRating returns a player's rating, NewGlicko for players without rated
+// games (or without MongoDB)
+func GetRating(player string) (PlayerRating, error) {
+	if Client == nil {
+		return PlayerRating{Player: player, Glicko: NewGlicko}, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return findRating(ctx, player)
+}
+
+// findRating reads a player's rating within ctx, which may be a transaction
+func findRating(ctx context.Context, player string) (PlayerRating, error) {
+	r := PlayerRating{Player: player, Glicko: NewGlicko}
+	err := ratings().FindOne(ctx, bson.M{"_id": player}).Decode(&r)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return r, nil
+	}
+	return r, err
+}
+
+// resultScore is player 1's score in a game won by winner, 0 for a draw
+func resultScore(winner int) float64 {
+	switch winner {
+	case 1:
+		return 1
+	case 2:
+		return 0
+	}
+	return 0.5
+}
+
+// rateGame updates both players' ratings for a game within ctx, which
+// should be a transaction, and returns the changes by player
+func rateGame(ctx context.Context, player1, player2 string, winner int) (map[string]RatingChange, error) {
+	r1, err := findRating(ctx, player1)
+	if err != nil {
+		return nil, err
+	}
+	r2, err := findRating(ctx, player2)
+	if err != nil {
+		return nil, err
+	}
+
+	score1 := resultScore(winner)
+	// Both updates use the ratings from before the game
+	new1 := r1.Update([]Outcome{{Opponent: r2.Glicko, Score: score1}})
+	new2 := r2.Update([]Outcome{{Opponent: r1.Glicko, Score: 1 - score1}})
+
+	for _, u := range []struct {
+		player string
+		glicko Glicko
+		score  float64
+	}{
+		{player1, new1, score1},
+		{player2, new2, 1 - score1},
+	} {
+		inc := bson.M{"games": 1}
+		switch u.score {
+		case 1:
+			inc["wins"] = 1
+		case 0:
+			inc["losses"] = 1
+		default:
+			inc["draws"] = 1
+		}
+		update := bson.M{
+			"$set": bson.M{
+				"rating":     u.glicko.Rating,
+				"deviation":  u.glicko.Deviation,
+				"volatility": u.glicko.Volatility,
+				"updatedAt":  time.Now(),
+			},
+			"$inc": inc,
+		}
+		if _, err := ratings().UpdateByID(ctx, u.player, update, options.Update().SetUpsert(true)); err != nil {
+			return nil, err
+		}
+	}
+
+	return map[string]RatingChange{
+		player1: {Rating: new1.Rating, Delta: new1.Rating - r1.Rating},
+		player2: {Rating: new2.Rating, Delta: new2.Rating - r2.Rating},
+	}, nil
+}
@@ -0,0 +1,442 @@
+package game
+
+import (
+	"math/bits"
+	"sort"
+	"sync"
+)
+
+// Bitboard layout: each column takes Rows+1 bits (the playable rows plus a
+// sentinel bit that keeps shifted lines from wrapping into the next column).
+// Bit col*(Rows+1) + row holds the cell in that column, with row 0 at the
+// bottom. For the classic 6x7 board:
+//
+//	6 13 20 27 34 41 48   <- sentinel row
+//	5 12 19 26 33 40 47
+//	4 11 18 25 32 39 46
+//	3 10 17 24 31 38 45
+//	2  9 16 23 30 37 44
+//	1  8 15 22 29 36 43
+//	0  7 14 21 28 35 42
+
+// geometry holds the masks and tables derived from one set of Rules.
+// Positions share a pointer to it so copying a Position stays cheap.
+type geometry struct {
+	rules      Rules
+	height     int      // bits per column, Rows+1
+	cells      int      // playable cells, Rows*Columns
+	bottomMask uint64   // bottom cell of every column
+	boardMask  uint64   // every playable cell
+	order      []int    // columns centre-first, so searches see strong moves early
+	windows    []uint64 // every line of Connect cells, for the evaluation
+	centre     uint64   // the centre column(s), for the evaluation
+}
+
+var (
+	geometries     = make(map[Rules]*geometry)
+	geometriesLock sync.Mutex
+)
+
+// classicGeometry is the geometry of ClassicRules
+var classicGeometry = geometryFor(ClassicRules)
+
+// geometryFor returns the shared geometry for rules, building it on first use
+func geometryFor(rules Rules) *geometry {
+	geometriesLock.Lock()
+	defer geometriesLock.Unlock()
+
+	if g, ok := geometries[rules]; ok {
+		return g
+	}
+
+	g := &geometry{
+		rules:  rules,
+		height: rules.Rows + 1,
+		cells:  rules.Rows * rules.Columns,
+	}
+	for col := 0; col < rules.Columns; col++ {
+		g.bottomMask |= g.cellBit(col, 0)
+	}
+	g.boardMask = g.bottomMask * (uint64(1)<<rules.Rows - 1)
+
+	g.order = make([]int, rules.Columns)
+	for col := range g.order {
+		g.order[col] = col
+	}
+	sort.SliceStable(g.order, func(i, j int) bool {
+		return centreDistance(g.order[i], rules.Columns) < centreDistance(g.order[j], rules.Columns)
+	})
+
+	g.windows, g.centre = g.buildEvalMasks()
+	geometries[rules] = g
+	return g
+}
+
+// centreDistance is twice the distance of column from the middle of the board
+func centreDistance(column, columns int) int {
+	d := 2*column - (columns - 1)
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// cellBit returns the bit for a column and a height counted from the bottom
+func (g *geometry) cellBit(column, height int) uint64 {
+	return uint64(1) << (column*g.height + height)
+}
+
+// columnMask returns the playable cells of a column
+func (g *geometry) columnMask(column int) uint64 {
+	return (uint64(1)<<g.rules.Rows - 1) << (column * g.height)
+}
+
+// shifts returns the bit distance between neighbouring cells vertically,
+// horizontally and along both diagonals
+func (g *geometry) shifts() [4]uint {
+	h := uint(g.height)
+	return [4]uint{1, h, h + 1, h - 1}
+}
+
+// hasConnect checks a single player's mask for Connect in a row by shifting
+// the mask along each direction
+func (g *geometry) hasConnect(m uint64) bool {
+	for _, shift := range g.shifts() {
+		line := m
+		for i := 1; i < g.rules.Connect && line != 0; i++ {
+			line &= m >> (uint(i) * shift)
+		}
+		if line != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// winningCells returns the empty cells that would complete a line for the
+// player owning discs
+func (g *geometry) winningCells(discs, mask uint64) uint64 {
+	n := g.rules.Connect
+
+	// vertical: the empty cell can only be on top
+	r := discs << 1
+	for i := 2; i < n; i++ {
+		r &= discs << uint(i)
+	}
+
+	// horizontal and both diagonals: the empty cell can be anywhere in the line
+	shifts := g.shifts()
+	for _, shift := range shifts[1:] {
+		for gap := 0; gap < n; gap++ {
+			line := ^uint64(0)
+			for i := 0; i < n; i++ {
+				switch {
+				case i < gap:
+					line &= discs << (uint(gap-i) * shift)
+				case i > gap:
+					line &= discs >> (uint(i-gap) * shift)
+				}
+			}
+			r |= line
+		}
+	}
+
+	return r & (g.boardMask ^ mask)
+}
+
+// buildEvalMasks precomputes the windows and centre mask used by
+// evaluatePosition
+func (g *geometry) buildEvalMasks() ([]uint64, uint64) {
+	rows, columns, n := g.rules.Rows, g.rules.Columns, g.rules.Connect
+
+	var windows []uint64
+	// dc, dh pairs: horizontal, vertical, diagonal /, diagonal \
+	directions := [4][2]int{{1, 0}, {0, 1}, {1, 1}, {1, -1}}
+	for _, d := range directions {
+		for col := 0; col < columns; col++ {
+			for h := 0; h < rows; h++ {
+				endCol, endH := col+(n-1)*d[0], h+(n-1)*d[1]
+				if endCol >= columns || endH < 0 || endH >= rows {
+					continue
+				}
+				var w uint64
+				for i := 0; i < n; i++ {
+					w |= g.cellBit(col+i*d[0], h+i*d[1])
+				}
+				windows = append(windows, w)
+			}
+		}
+	}
+
+	var centre uint64
+	for col := 0; col < columns; col++ {
+		if centreDistance(col, columns) <= 1 {
+			centre |= g.columnMask(col)
+		}
+	}
+	return windows, centre
+}
+
+// Position is a bitboard Connect Four position: one mask per player plus
+// column heights, so playing a move and testing for a win are O(1)
+type Position struct {
+	geo     *geometry
+	discs   [2]uint64       // discs[0] is player 1, discs[1] is player 2
+	heights [maxColumns]int // number of discs in each column
+	turn    int             // player to move, 1 or 2
+	moves   int             // number of discs on the board
+}
+
+// NewPosition returns the empty classic position with player 1 to move
+func NewPosition() Position {
+	return Position{geo: classicGeometry, turn: 1}
+}
+
+// NewPositionWithRules returns the empty position for rules with player 1
+// to move. The rules must be valid.
+func NewPositionWithRules(rules Rules) Position {
+	return Position{geo: geometryFor(rules), turn: 1}
+}
+
+// PositionFromBoard converts a board (row 0 at the top) played under rules
+// into a Position with turn to move
+func PositionFromBoard(rules Rules, board Board, turn int) Position {
+	p := NewPositionWithRules(rules)
+	p.turn = turn
+	for col := 0; col < rules.Columns; col++ {
+		for row := rules.Rows - 1; row >= 0; row-- {
+			player := board[row][col]
+			if player != 1 && player != 2 {
+				break
+			}
+			p.discs[player-1] |= p.geo.cellBit(col, p.heights[col])
+			p.heights[col]++
+			p.moves++
+		}
+	}
+	return p
+}
+
+// GamePosition returns the bitboard of g's board with g.Turn to move
+func GamePosition(g *Game) Position {
+	return PositionFromBoard(g.Rules, g.Board, g.Turn)
+}
+
+// Board converts the position back into the Board layout used by Game
+func (p Position) Board() Board {
+	rows := p.geo.rules.Rows
+	board := NewBoard(p.geo.rules)
+	for col := 0; col < p.geo.rules.Columns; col++ {
+		for h := 0; h < p.heights[col]; h++ {
+			if p.discs[0]&p.geo.cellBit(col, h) != 0 {
+				board[rows-1-h][col] = 1
+			} else {
+				board[rows-1-h][col] = 2
+			}
+		}
+	}
+	return board
+}
+
+// Rules returns the rules the position is played under
+func (p Position) Rules() Rules {
+	return p.geo.rules
+}
+
+// Columns returns the number of columns on the board
+func (p Position) Columns() int {
+	return p.geo.rules.Columns
+}
+
+// Turn returns the player to move
+func (p Position) Turn() int {
+	return p.turn
+}
+
+// Moves returns how many discs have been played
+func (p Position) Moves() int {
+	return p.moves
+}
+
+// Key identifies the discs on the board in a uint64, as the player to
+// move's discs plus the mask. The side to move isn't part of it: the empty
+// board has Key 0 whoever is to move, and a board with the colours swapped
+// and the other side to move has the same Key. It is only unique among
+// positions whose side to move follows from the number of discs, such as
+// those the solver sees, so PopOut counts repetitions by repetitionKey.
+func (p Position) Key() uint64 {
+	return p.discs[p.turn-1] + p.mask()
+}
+
+// CanonicalKey is Key, but equal for a position and its mirror image
+func (p Position) CanonicalKey() uint64 {
+	key, mirrored := p.Key(), p.mirror().Key()
+	if mirrored < key {
+		return mirrored
+	}
+	return key
+}
+
+// CanPlay reports whether column still has room
+func (p Position) CanPlay(column int) bool {
+	return column >= 0 && column < p.geo.rules.Columns && p.heights[column] < p.geo.rules.Rows
+}
+
+// Play drops a disc for the player to move and passes the turn.
+// The caller must check CanPlay first.
+func (p *Position) Play(column int) {
+	p.discs[p.turn-1] |= p.geo.cellBit(column, p.heights[column])
+	p.heights[column]++
+	p.moves++
+	p.turn = opponent(p.turn)
+}
+
+// CanPop reports whether the player to move may pop their own disc from the
+// bottom of column. Only PopOut rules allow popping.
+func (p Position) CanPop(column int) bool {
+	return p.geo.rules.PopOut && column >= 0 && column < p.geo.rules.Columns &&
+		p.heights[column] > 0 && p.current()&p.geo.cellBit(column, 0) != 0
+}
+
+// Pop removes the bottom disc of column, drops the discs above it one row
+// and passes the turn. The caller must check CanPop first, and HasWon for
+// both players afterwards, since a pop can complete lines for either side.
+func (p *Position) Pop(column int) {
+	colMask := p.geo.columnMask(column)
+	for i := range p.discs {
+		p.discs[i] = p.discs[i]&^colMask | (p.discs[i]&colMask)>>1&colMask
+	}
+	p.heights[column]--
+	p.moves--
+	p.turn = opponent(p.turn)
+}
+
+// PlayMove plays a drop or a pop. The caller must check CanPlayMove first.
+func (p *Position) PlayMove(m Move) {
+	if m.Pop {
+		p.Pop(m.Column)
+	} else {
+		p.Play(m.Column)
+	}
+}
+
+// CanPlayMove reports whether m is a legal drop or pop
+func (p Position) CanPlayMove(m Move) bool {
+	if m.Pop {
+		return p.CanPop(m.Column)
+	}
+	return p.CanPlay(m.Column)
+}
+
+// LegalMoves lists every drop and, under PopOut rules, every pop available
+// to the player to move, centre columns first
+func (p Position) LegalMoves() []Move {
+	moves := make([]Move, 0, p.candidateMoves())
+	for i := 0; i < p.candidateMoves(); i++ {
+		if m := p.candidateMove(i); p.CanPlayMove(m) {
+			moves = append(moves, m)
+		}
+	}
+	return moves
+}
+
+// candidateMoves is the number of moves candidateMove enumerates: one drop
+// per column, plus one pop per column under PopOut rules
+func (p Position) candidateMoves() int {
+	if p.geo.rules.PopOut {
+		return 2 * p.geo.rules.Columns
+	}
+	return p.geo.rules.Columns
+}
+
+// candidateMove returns the i'th move in search order, legal or not: drops
+// centre-first, then pops centre-first. Searches loop over it instead of
+// LegalMoves to avoid allocating at every node.
+func (p Position) candidateMove(i int) Move {
+	columns := p.geo.rules.Columns
+	return Move{Column: p.geo.order[i%columns], Pop: i >= columns}
+}
+
+// IsWinningMove reports whether playing column wins for the player to move
+func (p Position) IsWinningMove(column int) bool {
+	if !p.CanPlay(column) {
+		return false
+	}
+	return p.geo.hasConnect(p.discs[p.turn-1] | p.geo.cellBit(column, p.heights[column]))
+}
+
+// HasWon reports whether player has a winning line
+func (p Position) HasWon(player int) bool {
+	return p.geo.hasConnect(p.discs[player-1])
+}
+
+// IsFull reports whether every column is full
+func (p Position) IsFull() bool {
+	return p.moves == p.geo.cells
+}
+
+// mask returns every occupied cell
+func (p Position) mask() uint64 {
+	return p.discs[0] | p.discs[1]
+}
+
+// current returns the discs of the player to move
+func (p Position) current() uint64 {
+	return p.discs[p.turn-1]
+}
+
+// possible returns the cell each playable column would fill
+func (p Position) possible() uint64 {
+	return (p.mask() + p.geo.bottomMask) & p.geo.boardMask
+}
+
+// CanWinNext reports whether the player to move has an immediate win
+func (p Position) CanWinNext() bool {
+	return p.geo.winningCells(p.current(), p.mask())&p.possible() != 0
+}
+
+// nonLosingMoves returns the playable cells that don't hand the opponent
+// an immediate win, or 0 if every move loses
+func (p Position) nonLosingMoves() uint64 {
+	possible := p.possible()
+	opponentWins := p.geo.winningCells(p.discs[opponent(p.turn)-1], p.mask())
+	forced := possible & opponentWins
+	if forced != 0 {
+		if forced&(forced-1) != 0 {
+			return 0 // two immediate threats, can't block both
+		}
+		possible = forced
+	}
+	// don't play directly below an opponent winning cell
+	return possible &^ (opponentWins >> 1)
+}
+
+// moveScore counts the winning cells the player to move would have after
+// playing move, used to order moves in the solver
+func (p Position) moveScore(move uint64) int {
+	return popCount(p.geo.winningCells(p.current()|move, p.mask()))
+}
+
+// mirror returns the position reflected left to right
+func (p Position) mirror() Position {
+	m := Position{geo: p.geo, turn: p.turn, moves: p.moves}
+	last := p.geo.rules.Columns - 1
+	for col := 0; col <= last; col++ {
+		shift := (last - 2*col) * p.geo.height
+		colMask := p.geo.columnMask(col)
+		for i := 0; i < 2; i++ {
+			if shift >= 0 {
+				m.discs[i] |= (p.discs[i] & colMask) << shift
+			} else {
+				m.discs[i] |= (p.discs[i] & colMask) >> -shift
+			}
+		}
+		m.heights[last-col] = p.heights[col]
+	}
+	return m
+}
+
+// popCount counts the set bits in a mask
+func popCount(m uint64) int {
+	return bits.OnesCount64(m)
+}
@@ -0,0 +1,269 @@
+package game
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// randomPosition plays up to n random moves under rules, stopping before
+// a move that would win so the result is undecided
+func randomPosition(rules Rules, n int, rng *rand.Rand) Position {
+	p := NewPositionWithRules(rules)
+	for i := 0; i < n && !p.IsFull(); i++ {
+		col := rng.Intn(rules.Columns)
+		if !p.CanPlay(col) {
+			continue
+		}
+		if p.IsWinningMove(col) {
+			break
+		}
+		p.Play(col)
+	}
+	return p
+}
+
+// mirrorMoves reflects a classic move sequence left to right
+func mirrorMoves(moves string) string {
+	mirrored := []byte(moves)
+	for i, ch := range mirrored {
+		mirrored[i] = '8' - ch + '0'
+	}
+	return string(mirrored)
+}
+
+func TestPositionBoardRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules Rules
+		board string
+	}{
+		{"empty", ClassicRules, "7/7/7/7/7/7 x"},
+		{"opening", ClassicRules, "7/7/7/7/3o3/2oxx2 x"},
+		{"full column", ClassicRules, "3o3/3x3/3o3/3x3/3o3/3x3 o"},
+		{"full board", ClassicRules, "xoxoxox/xoxoxox/oxoxoxo/oxoxoxo/xoxoxox/xoxoxox x"},
+		{"7x8", Rules{Rows: 7, Columns: 8, Connect: 4}, "8/8/8/8/8/o7/xx5o o"},
+		{"6x9 connect 5", Rules{Rows: 6, Columns: 9, Connect: 5}, "9/9/9/8x/o7o/xo2x3x x"},
+		{"12 columns", Rules{Rows: 4, Columns: 12, Connect: 4}, "12/12/11o/o10x x"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			board, turn, err := ParseBoard(tc.board)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := PositionFromBoard(tc.rules, board, turn)
+			if got := p.Board(); !reflect.DeepEqual(got, board) {
+				t.Errorf("Board() = %v, want %v", got, board)
+			}
+			discs := 0
+			for _, row := range board {
+				for _, cell := range row {
+					if cell != 0 {
+						discs++
+					}
+				}
+			}
+			if p.Turn() != turn || p.Moves() != discs {
+				t.Errorf("turn %d after %d moves, want %d after %d", p.Turn(), p.Moves(), turn, discs)
+			}
+		})
+	}
+
+	// Boards from random play, on every board size above
+	rng := rand.New(rand.NewSource(1))
+	for _, tc := range tests {
+		for i := 0; i < 50; i++ {
+			p := randomPosition(tc.rules, rng.Intn(tc.rules.Rows*tc.rules.Columns), rng)
+			q := PositionFromBoard(tc.rules, p.Board(), p.Turn())
+			if q.Key() != p.Key() || q.Moves() != p.Moves() || q.heights != p.heights {
+				t.Errorf("%s: %s doesn't survive the round trip", tc.rules, p)
+			}
+		}
+	}
+}
+
+func TestCanonicalKeyMirror(t *testing.T) {
+	tests := []struct {
+		moves     string
+		symmetric bool // the position is its own mirror image
+	}{
+		{"4", true},
+		{"1", false},
+		{"44", true},
+		{"12", false},
+		{"4453", false},
+		{"112233", false},
+		{"7654321", true}, // the same discs as 1234567
+		{"1357246", false},
+	}
+	for _, tc := range tests {
+		moves := tc.moves
+		p, err := ParseMoves(moves)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := ParseMoves(mirrorMoves(moves))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.CanonicalKey() != m.CanonicalKey() {
+			t.Errorf("%q and its mirror have different canonical keys", moves)
+		}
+		if m.Key() != p.mirror().Key() {
+			t.Errorf("mirror() of %q isn't %q", moves, mirrorMoves(moves))
+		}
+		if (p.Key() == m.Key()) != tc.symmetric {
+			t.Errorf("%q: Key equals its mirror's %v, want %v", moves, p.Key() == m.Key(), tc.symmetric)
+		}
+	}
+
+	// Different positions keep different keys
+	a, _ := ParseMoves("12")
+	b, _ := ParseMoves("21")
+	if a.Key() == b.Key() || a.CanonicalKey() == b.CanonicalKey() {
+		t.Error("12 and 21 share a key")
+	}
+	// The side to move is part of the key
+	c, _ := ParseMoves("1")
+	d := c
+	d.turn = 1
+	if c.Key() == d.Key() {
+		t.Error("side to move not in the key")
+	}
+}
+
+func TestIsWinningMove(t *testing.T) {
+	tests := []struct {
+		name string
+		rows [6]string // player 1 to move
+		wins []int     // columns that win, every other one doesn't
+	}{
+		{
+			name: "vertical",
+			rows: [6]string{".......", ".......", ".......", "X......", "X.....O", "X....OO"},
+			wins: []int{0},
+		},
+		{
+			name: "horizontal",
+			rows: [6]string{".......", ".......", ".......", ".......", ".OOO...", ".XXX..."},
+			wins: []int{0, 4},
+		},
+		{
+			name: "horizontal gap",
+			rows: [6]string{".......", ".......", ".......", ".......", "OO.O...", "XX.X..."},
+			wins: []int{2},
+		},
+		{
+			name: "rising diagonal",
+			rows: [6]string{".......", ".......", ".......", "..XO...", ".XOX...", "XOOX..O"},
+			wins: []int{3},
+		},
+		{
+			name: "falling diagonal",
+			rows: [6]string{".......", ".......", ".......", "...OX..", "...XOX.", "O..XOOX"},
+			wins: []int{3},
+		},
+		{
+			name: "no line",
+			rows: [6]string{".......", ".......", ".......", "X......", "O......", "XX.OO.."},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := PositionFromBoard(ClassicRules, boardFromRows(t, tc.rows), 1)
+			for col := 0; col < 7; col++ {
+				if got, want := p.IsWinningMove(col), containsInt(tc.wins, col); got != want {
+					t.Errorf("column %d: IsWinningMove = %v, want %v", col, got, want)
+				}
+			}
+		})
+	}
+
+	// A full column never wins, even if a disc there would complete a line
+	p, _ := ParseMoves("1112111")
+	if p.CanPlay(0) || p.IsWinningMove(0) {
+		t.Error("full column counted as a winning move")
+	}
+}
+
+func TestSentinelRowStopsWrapping(t *testing.T) {
+	for _, rules := range []Rules{ClassicRules, {Rows: 7, Columns: 8, Connect: 4}, {Rows: 6, Columns: 9, Connect: 5}} {
+		g := geometryFor(rules)
+		n := rules.Connect
+		for col := 0; col+1 < rules.Columns; col++ {
+			// k discs at the top of col and the rest at the bottom of the
+			// next one: consecutive bits but for the sentinel between them
+			for k := 1; k < n; k++ {
+				var m uint64
+				for h := rules.Rows - k; h < rules.Rows; h++ {
+					m |= g.cellBit(col, h)
+				}
+				for h := 0; h < n-k; h++ {
+					m |= g.cellBit(col+1, h)
+				}
+				if g.hasConnect(m) {
+					t.Errorf("%s: top %d of column %d and bottom %d of the next make a line", rules, k, col, n-k)
+				}
+				last := g.cellBit(col+1, n-k-1)
+				if g.winningCells(m&^last, m&^last)&last != 0 {
+					t.Errorf("%s: wrapped line gives a winning cell in column %d", rules, col+1)
+				}
+			}
+		}
+	}
+}
+
+func TestWinningCells(t *testing.T) {
+	// Hand-built: the cells include ones that can't be played yet
+	board := boardFromRows(t, [6]string{
+		".......",
+		".......",
+		".......",
+		"X......",
+		"X.OO...",
+		"XOXXX.O",
+	})
+	p := PositionFromBoard(ClassicRules, board, 1)
+	want := map[Cell]bool{
+		{Row: 2, Column: 0}: true, // on top of the column
+		{Row: 5, Column: 5}: true, // end of the bottom row
+	}
+	got := map[Cell]bool{}
+	cells := p.geo.winningCells(p.discs[0], p.mask())
+	for col := 0; col < 7; col++ {
+		for h := 0; h < 6; h++ {
+			if cells&p.geo.cellBit(col, h) != 0 {
+				got[Cell{Row: 5 - h, Column: col}] = true
+			}
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("player 1's winning cells %v, want %v", got, want)
+	}
+	if cells := p.geo.winningCells(p.discs[1], p.mask()); cells != 0 {
+		t.Errorf("player 2's winning cells %b, want none", cells)
+	}
+
+	// Random positions against placing a disc on every empty cell
+	rng := rand.New(rand.NewSource(2))
+	for _, rules := range []Rules{ClassicRules, {Rows: 7, Columns: 8, Connect: 4}, {Rows: 6, Columns: 9, Connect: 5}, {Rows: 4, Columns: 12, Connect: 4}} {
+		g := geometryFor(rules)
+		for i := 0; i < 200; i++ {
+			p := randomPosition(rules, rng.Intn(rules.Rows*rules.Columns), rng)
+			for _, discs := range p.discs {
+				var want uint64
+				for col := 0; col < rules.Columns; col++ {
+					for h := p.heights[col]; h < rules.Rows; h++ {
+						if bit := g.cellBit(col, h); g.hasConnect(discs | bit) {
+							want |= bit
+						}
+					}
+				}
+				if got := g.winningCells(discs, p.mask()); got != want {
+					t.Fatalf("%s, %s: winning cells %x, want %x", rules, p, got, want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package game
+
+import (
+	"bufio"
+	_ "embed"
+	"log"
+	"strconv"
+	"strings"
+)
+
+//go:embed opening_book.txt
+var openingBookData string
+
+// openingBook maps Position.CanonicalKey of early positions to their solver
+// score, so it answers for a position and its mirror image alike.
+// openingBookDiscs is the most discs any of them has.
+var openingBook, openingBookDiscs = parseOpeningBook(openingBookData)
+
+// lookupBook returns the book score of p, if it has one. It is cheap for
+// positions past the book, so the solver checks it at every node.
+func lookupBook(p Position) (int, bool) {
+	if p.geo != classicGeometry || p.moves > openingBookDiscs {
+		return 0, false
+	}
+	score, ok := openingBook[p.CanonicalKey()]
+	return score, ok
+}
+
+// bookCovers reports whether the book scores every move in p (or the move
+// wins at once, or leaves a win the solver sees at once), so the solver
+// picks a move without searching
+func bookCovers(p Position) bool {
+	for c := 0; c < p.Columns(); c++ {
+		if !p.CanPlay(c) || p.IsWinningMove(c) {
+			continue
+		}
+		next := p
+		next.Play(c)
+		if _, ok := lookupBook(next); !ok && !next.CanWinNext() {
+			return false
+		}
+	}
+	return true
+}
+
+// parseOpeningBook reads lines of "<moves> <score>", where moves are 1-based
+// column digits played from the empty board ("-" for the empty board)
+func parseOpeningBook(data string) (map[uint64]int, int) {
+	book := make(map[uint64]int)
+	discs := 0
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			log.Printf("opening book: skipping malformed line %q", line)
+			continue
+		}
+		p, err := ParseMoves(strings.TrimPrefix(fields[0], "-"))
+		if err != nil {
+			log.Printf("opening book: skipping line %q: %v", line, err)
+			continue
+		}
+		score, err := strconv.Atoi(fields[1])
+		if err != nil {
+			log.Printf("opening book: skipping line %q: %v", line, err)
+			continue
+		}
+		book[p.CanonicalKey()] = score
+		if p.Moves() > discs {
+			discs = p.Moves()
+		}
+	}
+	return book, discs
+}
@@ -2,81 +2,76 @@ package game
 
 import "math/rand"
 
-// copyBoard creates a copy of the board
-func copyBoard(board [6][7]int) [6][7]int {
-	var newBoard [6][7]int
-	for i := 0; i < 6; i++ {
-		for j := 0; j < 7; j++ {
-			newBoard[i][j] = board[i][j]
-		}
-	}
-	return newBoard
+// CountThreats counts the empty cells where player would complete connect
+// in a row, in any direction. See AnalyzeThreats for the full breakdown.
+func CountThreats(board Board, player int, connect int) int {
+	return len(AnalyzeThreats(board, player, connect).Threats)
 }
 
-// CountThreats counts how many 3-in-a-row threats a player has
-func CountThreats(board [6][7]int, player int) int {
-	count := 0
-	// Check all possible 3-in-a-row patterns
-	// This is a simplified version - full implementation would check all directions
-	for row := 0; row < 6; row++ {
-		for col := 0; col < 5; col++ {
-			// Horizontal threat
-			if board[row][col] == player && board[row][col+1] == player && board[row][col+2] == player {
-				count++
-			}
-		}
+// BotMoveWithDepth picks a column for the player to move, searching depth plies ahead
+func BotMoveWithDepth(g *Game, depth int) int {
+	col, _ := SearchPosition(GamePosition(g), depth)
+	if col == -1 {
+		return g.Rules.Columns / 2 // Board full, nothing to search
 	}
-	return count
+	return col
 }
 
-func BotMove(g *Game) int {
+// HeuristicMove is the original one-ply priority strategy, kept as a weaker
+// opponent. It plays for whoever's turn it is.
+func HeuristicMove(g *Game) int {
+	me := g.Turn
+	them := opponent(me)
+	columns, connect := g.Rules.Columns, g.Rules.Connect
+
 	// 1️⃣ PRIORITY: Try winning move (bot can win)
-	for c := 0; c < 7; c++ {
-		tempBoard := copyBoard(g.Board)
-		if DropDisc(&tempBoard, c, 2) && CheckWin(tempBoard, 2) {
+	for c := 0; c < columns; c++ {
+		tempBoard := g.Board.Clone()
+		if DropDisc(tempBoard, c, me) && CheckWin(tempBoard, me, connect) {
 			return c
 		}
 	}
 
 	// 2️⃣ PRIORITY: Block player's winning move
-	for c := 0; c < 7; c++ {
-		tempBoard := copyBoard(g.Board)
-		if DropDisc(&tempBoard, c, 1) && CheckWin(tempBoard, 1) {
+	for c := 0; c < columns; c++ {
+		tempBoard := g.Board.Clone()
+		if DropDisc(tempBoard, c, them) && CheckWin(tempBoard, them, connect) {
 			return c
 		}
 	}
 
 	// 3️⃣ STRATEGY: Create own threat (3 in a row that can become 4)
+	ownThreats := CountThreats(g.Board, me, connect)
 	bestCol := -1
 	maxThreats := -1
-	for c := 0; c < 7; c++ {
-		tempBoard := copyBoard(g.Board)
-		if DropDisc(&tempBoard, c, 2) {
-			threats := CountThreats(tempBoard, 2)
+	for c := 0; c < columns; c++ {
+		tempBoard := g.Board.Clone()
+		if DropDisc(tempBoard, c, me) {
+			threats := CountThreats(tempBoard, me, connect)
 			if threats > maxThreats {
 				maxThreats = threats
 				bestCol = c
 			}
 		}
 	}
-	if bestCol != -1 && maxThreats > 0 {
+	if bestCol != -1 && maxThreats > ownThreats {
 		return bestCol
 	}
 
 	// 4️⃣ STRATEGY: Block player's threat (prevent 3 in a row)
-	for c := 0; c < 7; c++ {
-		tempBoard := copyBoard(g.Board)
-		if DropDisc(&tempBoard, c, 1) {
-			threats := CountThreats(tempBoard, 1)
-			if threats > 0 {
+	theirThreats := CountThreats(g.Board, them, connect)
+	for c := 0; c < columns; c++ {
+		tempBoard := g.Board.Clone()
+		if DropDisc(tempBoard, c, them) {
+			threats := CountThreats(tempBoard, them, connect)
+			if threats > theirThreats {
 				return c
 			}
 		}
 	}
 
 	// 5️⃣ PREFERENCE: Center columns are more valuable
-	centerCols := []int{3, 2, 4, 1, 5, 0, 6}
-	for _, c := range centerCols {
+	for _, c := range geometryFor(g.Rules).order {
 		if g.Board[0][c] == 0 {
 			return c
 		}
@@ -84,7 +79,7 @@ func BotMove(g *Game) int {
 
 	// 6️⃣ FALLBACK: Random valid column
 	valid := []int{}
-	for c := 0; c < 7; c++ {
+	for c := 0; c < columns; c++ {
 		if g.Board[0][c] == 0 {
 			valid = append(valid, c)
 		}
@@ -93,5 +88,5 @@ func BotMove(g *Game) int {
 	return valid[rand.Intn(len(valid))]
 	}
 
-	return 3 // Default to center
+	return columns / 2 // Default to center
 }
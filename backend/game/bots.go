@@ -0,0 +1,277 @@
+package game
+
+import (
+	"log"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BotPlayerName is the display name used for the bot's seat
+const BotPlayerName = "BOT"
+
+// BotMoveTime is how long a bot may think about a move in a live game
+const BotMoveTime = 3 * time.Second
+
+// BotPause is how long a bot waits before thinking, so its moves don't
+// land instantly
+const BotPause = 700 * time.Millisecond
+
+// Bot time management: a bot spends at most a botTimeShare-th of its bank
+// (plus half its increment) on a move, or a botPerMoveShare-th of a
+// per-move allowance, and skips the pause with less than botShortOnTime left
+const (
+	botTimeShare    = 20
+	botPerMoveShare = 3
+	botShortOnTime  = 30 * time.Second
+)
+
+// Bot is an AI strategy that can play a seat in a game
+type Bot interface {
+	// Name is the registry key, e.g. "strong"
+	Name() string
+	// ChooseMove returns a playable column for the player to move in p,
+	// ideally before deadline
+	ChooseMove(p Position, deadline time.Time) int
+}
+
+// PopOutBot is a Bot that can also pop discs. In PopOut games BotMove asks
+// it for a Move; bots without pop support only drop discs there.
+type PopOutBot interface {
+	Bot
+	// ChooseMoveOrPop returns a legal drop or pop for the player to move
+	ChooseMoveOrPop(p Position, deadline time.Time) Move
+}
+
+var (
+	botRegistry     = make(map[string]Bot)
+	botRegistryLock sync.RWMutex
+)
+
+func init() {
+	RegisterBot(randomBot{})
+	RegisterBot(heuristicBot{})
+	RegisterBot(searchBot{name: string(DifficultyStrong), depth: DefaultSearchDepth})
+	RegisterBot(solverBot{})
+	RegisterBot(mctsBot{config: DefaultMCTSConfig})
+}
+
+// RegisterBot adds a bot to the registry, replacing any bot with the same name
+func RegisterBot(b Bot) {
+	botRegistryLock.Lock()
+	defer botRegistryLock.Unlock()
+	botRegistry[b.Name()] = b
+}
+
+// UnregisterBot removes a bot from the registry
+func UnregisterBot(name string) {
+	botRegistryLock.Lock()
+	defer botRegistryLock.Unlock()
+	delete(botRegistry, name)
+}
+
+// LookupBot finds a registered bot by name
+func LookupBot(name string) (Bot, bool) {
+	botRegistryLock.RLock()
+	defer botRegistryLock.RUnlock()
+	b, ok := botRegistry[name]
+	return b, ok
+}
+
+// BotNames lists the registered bots in alphabetical order
+func BotNames() []string {
+	botRegistryLock.RLock()
+	defer botRegistryLock.RUnlock()
+	names := make([]string, 0, len(botRegistry))
+	for name := range botRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BotMove asks the game's bot for a move, giving it BotThinkTime to think.
+// Games without a registered bot fall back to the DefaultDifficulty bot.
+func BotMove(g *Game) Move {
+	return LegalBotMove(g, ThinkBotMove(g.BotName, GamePosition(g), BotThinkTime(g)))
+}
+
+// BotThinkTime is how long the bot may think about its move in g: BotMoveTime,
+// or less when its clock can't afford that
+func BotThinkTime(g *Game) time.Duration {
+	if !g.TimeControl.Timed() {
+		return BotMoveTime
+	}
+	left := TimeLeft(g, g.BotPlayer)
+	think := left/botTimeShare + g.TimeControl.Increment/2
+	if g.TimeControl.PerMove > 0 {
+		think = left / botPerMoveShare
+	}
+	return min(think, BotMoveTime)
+}
+
+// BotPauseTime is how long the bot waits before thinking in g: BotPause, or
+// nothing when it is short on time
+func BotPauseTime(g *Game) time.Duration {
+	if g.TimeControl.Timed() && TimeLeft(g, g.BotPlayer) < botShortOnTime {
+		return 0
+	}
+	return BotPause
+}
+
+// ThinkBotMove asks the bot called name for a move in p, giving it think
+// to think. It only needs the position, so a live game doesn't have to stay
+// locked while the bot thinks; LegalBotMove then checks the move against
+// the game.
+func ThinkBotMove(name string, p Position, think time.Duration) Move {
+	bot, ok := LookupBot(name)
+	if !ok {
+		log.Printf("Unknown bot %q, using %s", name, DefaultDifficulty)
+		bot, _ = LookupBot(DefaultDifficulty.BotName())
+	}
+	deadline := time.Now().Add(think)
+
+	popBot, ok := bot.(PopOutBot)
+	switch {
+	case p.Rules().PopOut && ok:
+		return popBot.ChooseMoveOrPop(p, deadline)
+	case p.Rules().PopOut && p.IsFull():
+		// a drop-only bot can't move on a full board, so search for a pop
+		return searchBot{depth: DefaultSearchDepth}.ChooseMoveOrPop(p, deadline)
+	default:
+		return Move{Column: bot.ChooseMove(p, deadline)}
+	}
+}
+
+// LegalBotMove returns the bot's move m if it is legal in g, otherwise any
+// legal move. Bots only know drops and pops, so a variant may rule their
+// move out (e.g. a full Power Up board).
+func LegalBotMove(g *Game, m Move) Move {
+	legal := VariantOf(g).LegalMoves(g, g.Turn)
+	for _, l := range legal {
+		if l == m {
+			return m
+		}
+	}
+	if len(legal) > 0 {
+		log.Printf("Bot %s chose illegal move %+v in game %s, playing %+v", g.BotName, m, g.ID, legal[0])
+		return legal[0]
+	}
+	return m
+}
+
+// randomBot plays a random column but takes an immediate win
+type randomBot struct{}
+
+func (randomBot) Name() string { return string(DifficultyBeginner) }
+
+func (randomBot) ChooseMove(p Position, deadline time.Time) int {
+	for c := 0; c < p.Columns(); c++ {
+		if p.IsWinningMove(c) {
+			return c
+		}
+	}
+	cols := legalColumns(p)
+	if len(cols) == 0 {
+		return p.Columns() / 2
+	}
+	return cols[rand.Intn(len(cols))]
+}
+
+func (randomBot) ChooseMoveOrPop(p Position, deadline time.Time) Move {
+	for c := 0; c < p.Columns(); c++ {
+		if p.IsWinningMove(c) {
+			return Move{Column: c}
+		}
+	}
+	moves := p.LegalMoves()
+	if len(moves) == 0 {
+		return Move{Column: p.Columns() / 2}
+	}
+	return moves[rand.Intn(len(moves))]
+}
+
+// heuristicBot is the original one-ply priority strategy
+type heuristicBot struct{}
+
+func (heuristicBot) Name() string { return string(DifficultyCasual) }
+
+func (heuristicBot) ChooseMove(p Position, deadline time.Time) int {
+	return HeuristicMove(&Game{Board: p.Board(), Rules: p.Rules(), Turn: p.Turn()})
+}
+
+// searchBot runs an alpha-beta search to a fixed depth, or as deep as it
+// gets before the deadline
+type searchBot struct {
+	name  string
+	depth int
+}
+
+func (b searchBot) Name() string { return b.name }
+
+func (b searchBot) ChooseMove(p Position, deadline time.Time) int {
+	m, _ := SearchMoveUntil(p, b.depth, deadline)
+	if m.Column == -1 {
+		return p.Columns() / 2
+	}
+	return m.Column
+}
+
+func (b searchBot) ChooseMoveOrPop(p Position, deadline time.Time) Move {
+	m, _ := SearchMoveUntil(p, b.depth, deadline)
+	if m.Column == -1 {
+		return Move{Column: p.Columns() / 2}
+	}
+	return m
+}
+
+// solverBot is the expert level. It plays perfectly once the solver can
+// finish in time, falling back to a deep search when it can't. The solver
+// gets two thirds of the time to the deadline and the search the rest;
+// positions with fewer than solverMinDiscs discs outside the opening book
+// go straight to the search, so the opening isn't perfect play.
+type solverBot struct{}
+
+func (solverBot) Name() string { return string(DifficultyExpert) }
+
+func (solverBot) ChooseMove(p Position, deadline time.Time) int {
+	if deadline.IsZero() || p.Moves() >= solverMinDiscs || bookCovers(p) {
+		solveBy := deadline
+		if !deadline.IsZero() {
+			solveBy = time.Now().Add(time.Until(deadline) * 2 / 3)
+		}
+		if col, ok := PerfectMove(p, solveBy); ok {
+			return col
+		}
+	}
+	return searchBot{depth: expertSearchDepth}.ChooseMove(p, deadline)
+}
+
+// ChooseMoveOrPop searches deeply, the solver doesn't know PopOut
+func (solverBot) ChooseMoveOrPop(p Position, deadline time.Time) Move {
+	return searchBot{depth: popOutSearchDepth}.ChooseMoveOrPop(p, deadline)
+}
+
+// mctsBot runs Monte Carlo Tree Search, stopping early at the deadline
+type mctsBot struct {
+	config MCTSConfig
+}
+
+func (mctsBot) Name() string { return string(DifficultyMCTS) }
+
+func (b mctsBot) ChooseMove(p Position, deadline time.Time) int {
+	cfg := b.config
+	if remaining := time.Until(deadline); !deadline.IsZero() && (cfg.TimeLimit == 0 || remaining < cfg.TimeLimit) {
+		if remaining <= 0 {
+			// A zero TimeLimit is no limit at all, so take the search's
+			// one-ply move instead
+			return searchBot{depth: DefaultSearchDepth}.ChooseMove(p, deadline)
+		}
+		cfg.TimeLimit = remaining
+	}
+	if col := MCTSMove(p, cfg); col != -1 {
+		return col
+	}
+	return p.Columns() / 2
+}
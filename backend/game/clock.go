@@ -0,0 +1,184 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeControl is a chess-style clock setting. The zero value is untimed.
+type TimeControl struct {
+	Initial   time.Duration // time bank per player, e.g. 3 minutes for 3+2
+	Increment time.Duration // added after each of the player's turns
+	PerMove   time.Duration // if set, a fresh allowance every turn and no bank
+}
+
+// Limits on TimeControl
+const (
+	maxInitial   = 3 * time.Hour
+	maxIncrement = time.Minute
+	minPerMove   = 5 * time.Second
+	maxPerMove   = time.Hour
+)
+
+// ParseTimeControl reads "3+2" (3 minutes plus 2 seconds a turn), "5"
+// (5 minutes, no increment) or "30s" (30 seconds per move). An empty
+// string is untimed.
+func ParseTimeControl(s string) (TimeControl, error) {
+	if s == "" {
+		return TimeControl{}, nil
+	}
+	if secs, ok := strings.CutSuffix(s, "s"); ok {
+		n, err := strconv.Atoi(secs)
+		if err != nil {
+			return TimeControl{}, fmt.Errorf("invalid time control %q", s)
+		}
+		tc := TimeControl{PerMove: time.Duration(n) * time.Second}
+		return tc, tc.Validate()
+	}
+	mins, inc, _ := strings.Cut(s, "+")
+	if inc == "" {
+		inc = "0"
+	}
+	m, err1 := strconv.Atoi(mins)
+	i, err2 := strconv.Atoi(inc)
+	if err1 != nil || err2 != nil {
+		return TimeControl{}, fmt.Errorf("invalid time control %q", s)
+	}
+	tc := TimeControl{Initial: time.Duration(m) * time.Minute, Increment: time.Duration(i) * time.Second}
+	return tc, tc.Validate()
+}
+
+// Validate checks that the time control is untimed or within limits
+func (tc TimeControl) Validate() error {
+	if tc.PerMove != 0 {
+		if tc.Initial != 0 || tc.Increment != 0 {
+			return errors.New("per-move time can't be combined with a time bank")
+		}
+		if tc.PerMove < minPerMove || tc.PerMove > maxPerMove {
+			return fmt.Errorf("time per move must be between %v and %v", minPerMove, maxPerMove)
+		}
+		return nil
+	}
+	if tc.Initial < 0 || tc.Initial > maxInitial {
+		return fmt.Errorf("time bank must be at most %v", maxInitial)
+	}
+	if tc.Increment < 0 || tc.Increment > maxIncrement {
+		return fmt.Errorf("increment must be at most %v", maxIncrement)
+	}
+	if tc.Initial == 0 && tc.Increment != 0 {
+		return errors.New("increment needs a time bank")
+	}
+	return nil
+}
+
+// Timed reports whether the time control has a clock at all
+func (tc TimeControl) Timed() bool {
+	return tc.Initial > 0 || tc.PerMove > 0
+}
+
+// String writes the time control the way ParseTimeControl reads it, or ""
+// if untimed
+func (tc TimeControl) String() string {
+	switch {
+	case tc.PerMove > 0:
+		return strconv.Itoa(int(tc.PerMove/time.Second)) + "s"
+	case tc.Initial > 0:
+		return strconv.FormatFloat(tc.Initial.Minutes(), 'f', -1, 64) + "+" + strconv.Itoa(int(tc.Increment/time.Second))
+	}
+	return ""
+}
+
+// clockNow tells the clocks the time. Tests replace it to run clocks
+// without waiting.
+var clockNow = time.Now
+
+// allowance is the time a player starts with
+func (tc TimeControl) allowance() time.Duration {
+	if tc.PerMove > 0 {
+		return tc.PerMove
+	}
+	return tc.Initial
+}
+
+// StartClock sets g's time control and starts player 1's clock
+func StartClock(g *Game, tc TimeControl) {
+	g.TimeControl = tc
+	g.Clocks = [2]time.Duration{tc.allowance(), tc.allowance()}
+	g.ClockStarted = clockNow()
+}
+
+// TimeLeft returns player's remaining time, counting down while it is
+// their turn. Untimed games always have zero.
+func TimeLeft(g *Game, player int) time.Duration {
+	if !g.TimeControl.Timed() {
+		return 0
+	}
+	left := g.Clocks[player-1]
+	if !g.GameOver && g.Turn == player {
+		left -= clockNow().Sub(g.ClockStarted)
+	}
+	if left < 0 {
+		left = 0
+	}
+	return left
+}
+
+// Flagged returns the player to move if their time has run out, else 0
+func Flagged(g *Game) int {
+	if !g.TimeControl.Timed() || g.GameOver || TimeLeft(g, g.Turn) > 0 {
+		return 0
+	}
+	return g.Turn
+}
+
+// TimeoutGame ends g as a loss for the player whose time ran out. It
+// reports false if nobody has flagged, so only one caller ends the game.
+func TimeoutGame(g *Game) bool {
+	player := Flagged(g)
+	if player == 0 {
+		return false
+	}
+	endGame(g, opponent(player), ReasonTimeout)
+	return true
+}
+
+// stopClock charges the player to move for the time since their clock
+// started and restarts the count from now
+func stopClock(g *Game) {
+	if !g.TimeControl.Timed() {
+		return
+	}
+	now := clockNow()
+	if !g.GameOver {
+		g.Clocks[g.Turn-1] -= now.Sub(g.ClockStarted)
+		if g.Clocks[g.Turn-1] < 0 {
+			g.Clocks[g.Turn-1] = 0
+		}
+	}
+	g.ClockStarted = now
+}
+
+// restartMoveClock gives the player to move a fresh allowance in per-move
+// games, after Undo hands them the turn
+func restartMoveClock(g *Game) {
+	if g.TimeControl.PerMove > 0 {
+		g.Clocks[g.Turn-1] = g.TimeControl.PerMove
+	}
+}
+
+// passClock gives player their increment once their turn has ended, and
+// a fresh allowance to the player now to move in per-move games
+func passClock(g *Game, player int) {
+	if !g.TimeControl.Timed() {
+		return
+	}
+	if g.GameOver || g.Turn != player {
+		g.Clocks[player-1] += g.TimeControl.Increment
+	}
+	if g.TimeControl.PerMove > 0 && !g.GameOver && g.Turn != player {
+		g.Clocks[g.Turn-1] = g.TimeControl.PerMove
+	}
+}
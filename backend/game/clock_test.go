@@ -0,0 +1,247 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock replaces the clocks' time source for the test. Advance it by
+// adding to the returned time.
+func fakeClock(t *testing.T) *time.Time {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clockNow = func() time.Time { return now }
+	t.Cleanup(func() { clockNow = time.Now })
+	return &now
+}
+
+// timedGame returns a classic game with its clock started
+func timedGame(t *testing.T, tc TimeControl) *Game {
+	t.Helper()
+	g := NewGame("alice", "bob")
+	StartClock(g, tc)
+	return g
+}
+
+// checkTimeLeft compares both players' clocks
+func checkTimeLeft(t *testing.T, g *Game, want1, want2 time.Duration) {
+	t.Helper()
+	if got1, got2 := TimeLeft(g, 1), TimeLeft(g, 2); got1 != want1 || got2 != want2 {
+		t.Errorf("time left %v and %v, want %v and %v", got1, got2, want1, want2)
+	}
+}
+
+func TestParseTimeControl(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    TimeControl
+		wantErr bool
+	}{
+		{s: "", want: TimeControl{}},
+		{s: "3+2", want: TimeControl{Initial: 3 * time.Minute, Increment: 2 * time.Second}},
+		{s: "5", want: TimeControl{Initial: 5 * time.Minute}},
+		{s: "30s", want: TimeControl{PerMove: 30 * time.Second}},
+		{s: "180+60", want: TimeControl{Initial: 3 * time.Hour, Increment: time.Minute}},
+		{s: "3600s", want: TimeControl{PerMove: time.Hour}},
+
+		{s: "abc", wantErr: true},
+		{s: "3+x", wantErr: true},
+		{s: "1.5", wantErr: true},
+		{s: "s", wantErr: true},
+		{s: "4s", wantErr: true},    // too short a move
+		{s: "3601s", wantErr: true}, // too long a move
+		{s: "181", wantErr: true},   // bank too big
+		{s: "3+61", wantErr: true},  // increment too big
+		{s: "0+5", wantErr: true},   // increment without a bank
+		{s: "-1", wantErr: true},
+		{s: "3+-1", wantErr: true},
+	}
+	for _, tc := range tests {
+		got, err := ParseTimeControl(tc.s)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%q: error %v, want error %v", tc.s, err, tc.wantErr)
+			continue
+		}
+		if tc.wantErr {
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%q: got %+v, want %+v", tc.s, got, tc.want)
+		}
+		if got.String() != tc.s && tc.s != "5" {
+			t.Errorf("%q: String() = %q", tc.s, got.String())
+		}
+	}
+
+	// "5" is written back with its increment
+	if tc, _ := ParseTimeControl("5"); tc.String() != "5+0" {
+		t.Errorf("String() of 5 minutes = %q", tc.String())
+	}
+	if err := (TimeControl{Initial: time.Minute, PerMove: 30 * time.Second}).Validate(); err == nil {
+		t.Error("per-move time with a bank accepted")
+	}
+}
+
+func TestClockIncrement(t *testing.T) {
+	now := fakeClock(t)
+	g := timedGame(t, TimeControl{Initial: 3 * time.Minute, Increment: 2 * time.Second})
+	checkTimeLeft(t, g, 3*time.Minute, 3*time.Minute)
+
+	*now = now.Add(10 * time.Second)
+	checkTimeLeft(t, g, 2*time.Minute+50*time.Second, 3*time.Minute)
+	MakeMove(g, 3, 1)
+	// Player 1 gets the increment, and their clock stops
+	*now = now.Add(5 * time.Second)
+	checkTimeLeft(t, g, 2*time.Minute+52*time.Second, 2*time.Minute+55*time.Second)
+
+	MakeMove(g, 3, 2)
+	checkTimeLeft(t, g, 2*time.Minute+52*time.Second, 2*time.Minute+57*time.Second)
+}
+
+func TestClockPerMove(t *testing.T) {
+	now := fakeClock(t)
+	g := timedGame(t, TimeControl{PerMove: 30 * time.Second})
+
+	*now = now.Add(20 * time.Second)
+	checkTimeLeft(t, g, 10*time.Second, 30*time.Second)
+	MakeMove(g, 3, 1)
+	*now = now.Add(25 * time.Second)
+	// Time left over isn't banked: player 1 gets a fresh 30s next turn
+	MakeMove(g, 3, 2)
+	checkTimeLeft(t, g, 30*time.Second, 5*time.Second)
+	*now = now.Add(29 * time.Second)
+	checkTimeLeft(t, g, time.Second, 5*time.Second)
+}
+
+func TestClockTimeout(t *testing.T) {
+	for _, tc := range []TimeControl{{Initial: time.Minute}, {PerMove: 10 * time.Second}} {
+		t.Run(tc.String(), func(t *testing.T) {
+			now := fakeClock(t)
+			g := timedGame(t, tc)
+			MakeMove(g, 3, 1)
+
+			*now = now.Add(tc.allowance() - time.Millisecond)
+			if Flagged(g) != 0 || TimeoutGame(g) {
+				t.Fatal("flagged with time left")
+			}
+			*now = now.Add(time.Millisecond)
+			if Flagged(g) != 2 {
+				t.Fatalf("Flagged = %d, want 2", Flagged(g))
+			}
+			if got := MakeMove(g, 3, 2); got != "Out of time" {
+				t.Errorf("move after the flag: %q", got)
+			}
+			if !TimeoutGame(g) {
+				t.Fatal("TimeoutGame didn't end the game")
+			}
+			if g.Winner != 1 || g.EndReason != ReasonTimeout {
+				t.Errorf("winner %d by %s, want 1 by timeout", g.Winner, g.EndReason)
+			}
+			if TimeoutGame(g) || Flagged(g) != 0 {
+				t.Error("game timed out twice")
+			}
+
+			// The clocks stop with the game
+			left := TimeLeft(g, 1)
+			*now = now.Add(time.Hour)
+			checkTimeLeft(t, g, left, 0)
+		})
+	}
+
+	// Untimed games never flag
+	now := fakeClock(t)
+	g := NewGame("alice", "bob")
+	*now = now.Add(24 * time.Hour)
+	if Flagged(g) != 0 || TimeoutGame(g) || TimeLeft(g, 1) != 0 {
+		t.Error("untimed game flagged")
+	}
+}
+
+func TestClockPausesOnUndo(t *testing.T) {
+	now := fakeClock(t)
+	g := timedGame(t, TimeControl{Initial: 3 * time.Minute})
+
+	*now = now.Add(10 * time.Second)
+	MakeMove(g, 3, 1)
+	*now = now.Add(20 * time.Second)
+	if err := Undo(g); err != nil {
+		t.Fatal(err)
+	}
+	// Player 2 spent 20s before the undo; player 1's clock picks up where
+	// it stopped, not counting the time it was player 2's turn
+	checkTimeLeft(t, g, 2*time.Minute+50*time.Second, 2*time.Minute+40*time.Second)
+	*now = now.Add(5 * time.Second)
+	checkTimeLeft(t, g, 2*time.Minute+45*time.Second, 2*time.Minute+40*time.Second)
+}
+
+func TestClockPausesOnTakeback(t *testing.T) {
+	tests := []struct {
+		name         string
+		tc           TimeControl
+		want1, want2 time.Duration // after the takeback
+	}{
+		{"bank", TimeControl{Initial: time.Minute, Increment: time.Second}, 42 * time.Second, 51 * time.Second},
+		{"per move", TimeControl{PerMove: 30 * time.Second}, 20 * time.Second, 30 * time.Second},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			now := fakeClock(t)
+			g := timedGame(t, tc.tc)
+
+			*now = now.Add(9 * time.Second)
+			MakeMove(g, 3, 1)
+			*now = now.Add(10 * time.Second)
+			MakeMove(g, 3, 2)
+			*now = now.Add(5 * time.Second)
+			if err := RequestTakeback(g, 2); err != nil {
+				t.Fatal(err)
+			}
+			// Player 1 thinks about the request on their own time, and
+			// player 2 gets the turn back with the clock they left it on
+			*now = now.Add(5 * time.Second)
+			if err := AcceptTakeback(g, 1); err != nil {
+				t.Fatal(err)
+			}
+			if g.Turn != 2 {
+				t.Fatalf("player %d to move after the takeback", g.Turn)
+			}
+			checkTimeLeft(t, g, tc.want1, tc.want2)
+
+			// Only player 2's clock runs again
+			*now = now.Add(time.Second)
+			checkTimeLeft(t, g, tc.want1, tc.want2-time.Second)
+		})
+	}
+}
+
+func TestBotManagesItsClock(t *testing.T) {
+	tests := []struct {
+		name  string
+		tc    TimeControl
+		used  time.Duration // off the bot's clock before its turn
+		think time.Duration
+		pause time.Duration
+	}{
+		{"untimed", TimeControl{}, 0, BotMoveTime, BotPause},
+		{"long bank", TimeControl{Initial: 10 * time.Minute}, 0, BotMoveTime, BotPause},
+		{"1+0", TimeControl{Initial: time.Minute}, 0, 3 * time.Second, BotPause},
+		{"1+0 low", TimeControl{Initial: time.Minute}, 40 * time.Second, time.Second, 0},
+		{"1+2 low", TimeControl{Initial: time.Minute, Increment: 2 * time.Second}, 40 * time.Second, 2 * time.Second, 0},
+		{"1+0 flagging", TimeControl{Initial: time.Minute}, time.Minute, 0, 0},
+		{"per move", TimeControl{PerMove: 5 * time.Second}, 0, 5 * time.Second / 3, 0},
+		{"long per move", TimeControl{PerMove: time.Minute}, 0, BotMoveTime, BotPause},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			now := fakeClock(t)
+			g := timedGame(t, tc.tc)
+			g.BotPlayer = 1
+			*now = now.Add(tc.used)
+			if got := BotThinkTime(g); got != tc.think {
+				t.Errorf("BotThinkTime = %v, want %v", got, tc.think)
+			}
+			if got := BotPauseTime(g); got != tc.pause {
+				t.Errorf("BotPauseTime = %v, want %v", got, tc.pause)
+			}
+		})
+	}
+}
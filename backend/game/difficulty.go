@@ -0,0 +1,80 @@
+package game
+
+import "math"
+
+// Difficulty selects how strong the bot plays. Each level is played by the
+// registered Bot of the same name.
+type Difficulty string
+
+const (
+	DifficultyBeginner Difficulty = "beginner" // random moves, but takes an immediate win
+	DifficultyCasual   Difficulty = "casual"   // one-ply priority heuristic
+	DifficultyStrong   Difficulty = "strong"   // alpha-beta search, DefaultSearchDepth plies
+	DifficultyExpert   Difficulty = "expert"   // deep search, exact solver from solverMinDiscs on
+	DifficultyMCTS     Difficulty = "mcts"     // Monte Carlo Tree Search, less predictable
+)
+
+// DefaultDifficulty is used when a player doesn't ask for one
+const DefaultDifficulty = DifficultyStrong
+
+// expertSearchDepth is how deep the expert bot searches positions it
+// can't solve in time, if the deadline allows
+const expertSearchDepth = 12
+
+// solverMinDiscs is how many discs must be on the board before the expert
+// bot tries to solve a position the opening book doesn't cover. Earlier
+// positions take far longer than BotMoveTime, so it searches instead.
+const solverMinDiscs = 10
+
+// popOutSearchDepth is how deep the expert bot searches in PopOut games,
+// which the solver doesn't cover, if the deadline allows. Pops roughly
+// double the branching factor, so this stays below expertSearchDepth.
+const popOutSearchDepth = 10
+
+// ParseDifficulty converts a query/message value into a Difficulty.
+// An empty string gives DefaultDifficulty.
+func ParseDifficulty(s string) (Difficulty, bool) {
+	switch Difficulty(s) {
+	case "":
+		return DefaultDifficulty, true
+	case DifficultyBeginner, DifficultyCasual, DifficultyStrong, DifficultyExpert, DifficultyMCTS:
+		return Difficulty(s), true
+	case "perfect": // the expert level's old name
+		return DifficultyExpert, true
+	}
+	return "", false
+}
+
+// BotName returns the registered bot that plays at this difficulty
+func (d Difficulty) BotName() string {
+	return string(d)
+}
+
+// Rating is roughly how strong the level plays on the player rating scale
+func (d Difficulty) Rating() float64 {
+	switch d {
+	case DifficultyBeginner:
+		return 800
+	case DifficultyCasual:
+		return 1200
+	case DifficultyStrong:
+		return 1700
+	case DifficultyMCTS:
+		return 1900
+	case DifficultyExpert:
+		return 2300
+	}
+	return DefaultRating
+}
+
+// DifficultyForRating returns the level whose Rating is closest to rating,
+// the bot a player of that rating gets when nobody else is found
+func DifficultyForRating(rating float64) Difficulty {
+	best := DefaultDifficulty
+	for _, d := range []Difficulty{DifficultyBeginner, DifficultyCasual, DifficultyStrong, DifficultyMCTS, DifficultyExpert} {
+		if math.Abs(d.Rating()-rating) < math.Abs(best.Rating()-rating) {
+			best = d
+		}
+	}
+	return best
+}
@@ -0,0 +1,81 @@
+package game
+
+import "errors"
+
+// EndReason says how a game ended
+type EndReason string
+
+const (
+	ReasonConnect4    EndReason = "connect4"    // a line of Rules.Connect discs
+	ReasonBoardFull   EndReason = "board_full"  // nobody can move and nobody has a line
+	ReasonRepetition  EndReason = "repetition"  // PopOut: the same position for the third time
+	ReasonResignation EndReason = "resignation" // the loser resigned
+	ReasonAgreement   EndReason = "agreement"   // a draw offer was accepted
+	ReasonTimeout     EndReason = "timeout"     // the loser's clock ran out
+	ReasonAbandonment EndReason = "abandonment" // the loser stayed disconnected too long
+)
+
+// moveEndReason says why the move just played ended the game
+func moveEndReason(g *Game) EndReason {
+	switch {
+	case g.Winner != 0:
+		return ReasonConnect4
+	case CheckDraw(g.Board):
+		return ReasonBoardFull
+	}
+	return ReasonRepetition
+}
+
+// Resign ends g as a loss for player
+func Resign(g *Game, player int) error {
+	if g.GameOver {
+		return errors.New("Game already finished")
+	}
+	endGame(g, opponent(player), ReasonResignation)
+	return nil
+}
+
+// OfferDraw offers player's opponent a draw. The offer stands until the
+// opponent answers with AcceptDraw or DeclineDraw, or makes a move.
+func OfferDraw(g *Game, player int) error {
+	if g.GameOver {
+		return errors.New("Game already finished")
+	}
+	if g.DrawOffer != 0 {
+		return errors.New("Draw already offered")
+	}
+	g.DrawOffer = player
+	return nil
+}
+
+// AcceptDraw accepts the opponent's draw offer, ending g as a draw
+func AcceptDraw(g *Game, player int) error {
+	if g.GameOver {
+		return errors.New("Game already finished")
+	}
+	if g.DrawOffer != opponent(player) {
+		return errors.New("No draw offer to accept")
+	}
+	endGame(g, 0, ReasonAgreement)
+	return nil
+}
+
+// DeclineDraw refuses the opponent's draw offer
+func DeclineDraw(g *Game, player int) error {
+	if g.DrawOffer != opponent(player) {
+		return errors.New("No draw offer to decline")
+	}
+	g.DrawOffer = 0
+	return nil
+}
+
+// endGame finishes g with winner (0 for a draw) for a reason other than
+// a move, stopping the clocks
+func endGame(g *Game, winner int, reason EndReason) {
+	stopClock(g)
+	g.GameOver = true
+	g.Winner = winner
+	g.EndReason = reason
+	g.DrawOffer = 0
+	g.TakebackRequest = 0
+}
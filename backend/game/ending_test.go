@@ -0,0 +1,128 @@
+package game
+
+import "testing"
+
+func TestResign(t *testing.T) {
+	for _, player := range []int{1, 2} {
+		g := NewGame("alice", "bob")
+		MakeMove(g, 3, 1)
+		// Either player can resign, on their turn or not
+		if err := Resign(g, player); err != nil {
+			t.Fatal(err)
+		}
+		if !g.GameOver || g.Winner != opponent(player) || g.EndReason != ReasonResignation {
+			t.Errorf("player %d resigned: over %v, winner %d by %s", player, g.GameOver, g.Winner, g.EndReason)
+		}
+	}
+}
+
+func TestDrawOffer(t *testing.T) {
+	g := NewGame("alice", "bob")
+	MakeMove(g, 3, 1)
+
+	if err := AcceptDraw(g, 2); err == nil {
+		t.Error("accepted a draw nobody offered")
+	}
+	if err := DeclineDraw(g, 2); err == nil {
+		t.Error("declined a draw nobody offered")
+	}
+	if err := OfferDraw(g, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := OfferDraw(g, 2); err == nil {
+		t.Error("offered a draw over a standing offer")
+	}
+	if err := AcceptDraw(g, 1); err == nil {
+		t.Error("player 1 accepted their own offer")
+	}
+
+	if err := DeclineDraw(g, 2); err != nil {
+		t.Fatal(err)
+	}
+	if g.DrawOffer != 0 || g.GameOver {
+		t.Errorf("after declining: offer from %d, over %v", g.DrawOffer, g.GameOver)
+	}
+
+	// Player 2 offers on their turn; it stands while they move
+	if err := OfferDraw(g, 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := MakeMove(g, 3, 2); got != "OK" || g.DrawOffer != 2 {
+		t.Fatalf("offerer's move %q cleared the offer: %d", got, g.DrawOffer)
+	}
+	if err := AcceptDraw(g, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !g.GameOver || g.Winner != 0 || g.EndReason != ReasonAgreement || g.DrawOffer != 0 {
+		t.Errorf("after accepting: over %v, winner %d by %s, offer from %d", g.GameOver, g.Winner, g.EndReason, g.DrawOffer)
+	}
+}
+
+func TestDrawOfferClearedByMove(t *testing.T) {
+	g := NewGame("alice", "bob")
+	MakeMove(g, 3, 1)
+	if err := OfferDraw(g, 1); err != nil {
+		t.Fatal(err)
+	}
+	// Moving instead of answering turns the offer down
+	if got := MakeMove(g, 4, 2); got != "OK" {
+		t.Fatalf("MakeMove = %q", got)
+	}
+	if g.DrawOffer != 0 {
+		t.Errorf("offer from %d still stands after the opponent moved", g.DrawOffer)
+	}
+	if err := AcceptDraw(g, 2); err == nil {
+		t.Error("accepted an offer the move cleared")
+	}
+
+	// A rejected move leaves it standing
+	if err := OfferDraw(g, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := MakeMove(g, 4, 2); got == "OK" {
+		t.Fatal("player 2 moved out of turn")
+	}
+	if g.DrawOffer != 1 {
+		t.Errorf("rejected move cleared the offer: %d", g.DrawOffer)
+	}
+}
+
+func TestEndingAfterGameOver(t *testing.T) {
+	g := NewGame("alice", "bob")
+	for _, col := range []int{1, 6, 1, 6, 1, 6, 1} {
+		MakeMove(g, col, g.Turn)
+	}
+	if !g.GameOver || g.Winner != 1 {
+		t.Fatalf("over %v, winner %d", g.GameOver, g.Winner)
+	}
+
+	if err := Resign(g, 1); err == nil {
+		t.Error("resigned a finished game")
+	}
+	if err := OfferDraw(g, 2); err == nil {
+		t.Error("offered a draw in a finished game")
+	}
+	if err := AcceptDraw(g, 2); err == nil {
+		t.Error("accepted a draw in a finished game")
+	}
+	if err := DeclineDraw(g, 2); err == nil {
+		t.Error("declined a draw in a finished game")
+	}
+	if g.Winner != 1 || g.EndReason != ReasonConnect4 || g.DrawOffer != 0 {
+		t.Errorf("result changed to winner %d by %s, offer from %d", g.Winner, g.EndReason, g.DrawOffer)
+	}
+
+	// A resigned game can't be resigned again or drawn either
+	g = NewGame("alice", "bob")
+	OfferDraw(g, 1)
+	Resign(g, 2)
+	if g.DrawOffer != 0 {
+		t.Error("resigning left the draw offer standing")
+	}
+	if err := Resign(g, 1); err == nil || g.Winner != 1 {
+		t.Errorf("second resignation: %v, winner %d", err, g.Winner)
+	}
+	if err := AcceptDraw(g, 2); err == nil || g.EndReason != ReasonResignation {
+		t.Errorf("draw accepted after resigning: %v, %s", err, g.EndReason)
+	}
+}
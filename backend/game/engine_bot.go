@@ -0,0 +1,285 @@
+package game
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// engineGrace is extra time an external engine gets past the move deadline
+// before we give up on it, to cover process and pipe overhead
+const engineGrace = 500 * time.Millisecond
+
+// engineStartTimeout bounds the c4i/c4iok handshake
+const engineStartTimeout = 5 * time.Second
+
+// engineMaxIdle is how many idle engine processes are kept for later
+// moves; busier moments start more and stop the extras afterwards
+const engineMaxIdle = 4
+
+// EngineBot runs an external program speaking the line-based engine
+// protocol described in ENGINE_PROTOCOL.md and plays its moves. Each move
+// runs on its own process, so games don't wait for each other: processes
+// are started when every running one is busy and reused afterwards. If an
+// engine fails or answers late, the strong search bot moves instead and
+// that process is replaced on a later request.
+type EngineBot struct {
+	name string
+	path string
+	args []string
+
+	mu     sync.Mutex
+	idle   []*engineProcess // Started and waiting for a position
+	closed bool             // Close was called: processes aren't kept
+}
+
+// engineProcess is one running engine
+type engineProcess struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	lines chan string   // Output lines, closed when the reader stops
+	done  chan struct{} // Closed by stop to release the reader
+	id    string        // Name the engine gave in the handshake
+}
+
+// NewEngineBot creates a bot that plays by running path with args
+func NewEngineBot(name, path string, args ...string) *EngineBot {
+	return &EngineBot{name: name, path: path, args: args}
+}
+
+// ParseEngineSpec parses "name=command arg..." into an EngineBot
+func ParseEngineSpec(spec string) (*EngineBot, error) {
+	name, command, ok := strings.Cut(spec, "=")
+	name = strings.TrimSpace(name)
+	fields := strings.Fields(command)
+	if !ok || name == "" || len(fields) == 0 {
+		return nil, fmt.Errorf("engine spec %q: want name=command [args...]", spec)
+	}
+	return NewEngineBot(name, fields[0], fields[1:]...), nil
+}
+
+// Name returns the registry name given to the engine
+func (e *EngineBot) Name() string {
+	return e.name
+}
+
+// ChooseMove sends the position to an engine process and waits for its
+// bestmove. A deadline that has already passed isn't sent to the engine.
+func (e *EngineBot) ChooseMove(p Position, deadline time.Time) int {
+	moveTime := BotMoveTime
+	if !deadline.IsZero() {
+		moveTime = time.Until(deadline)
+	}
+	if moveTime <= 0 {
+		log.Printf("Engine %s not asked, deadline already passed: using fallback move", e.name)
+		return searchBot{depth: DefaultSearchDepth}.ChooseMove(p, deadline)
+	}
+
+	proc, err := e.get()
+	col := -1
+	if err == nil {
+		col, err = proc.requestMove(p, moveTime)
+	}
+	if err != nil {
+		log.Printf("Engine %s failed, using fallback move: %v", e.name, err)
+		if proc != nil {
+			proc.stop()
+		}
+		return searchBot{depth: DefaultSearchDepth}.ChooseMove(p, deadline)
+	}
+	e.put(proc)
+	return col
+}
+
+// Close stops the idle engine processes. Moves still being thought about
+// finish, and their processes are stopped afterwards.
+func (e *EngineBot) Close() error {
+	e.mu.Lock()
+	idle := e.idle
+	e.idle = nil
+	e.closed = true
+	e.mu.Unlock()
+
+	for _, proc := range idle {
+		proc.stop()
+	}
+	return nil
+}
+
+// get takes an idle process, or starts one if there is none
+func (e *EngineBot) get() (*engineProcess, error) {
+	e.mu.Lock()
+	if n := len(e.idle); n > 0 {
+		proc := e.idle[n-1]
+		e.idle = e.idle[:n-1]
+		e.mu.Unlock()
+		return proc, nil
+	}
+	e.mu.Unlock()
+
+	proc, err := startEngine(e.path, e.args)
+	if err != nil {
+		return proc, err
+	}
+	log.Printf("Engine %s started (%s)", e.name, proc.id)
+	return proc, nil
+}
+
+// put returns a process that answered properly to the idle list
+func (e *EngineBot) put(proc *engineProcess) {
+	e.mu.Lock()
+	keep := !e.closed && len(e.idle) < engineMaxIdle
+	if keep {
+		e.idle = append(e.idle, proc)
+	}
+	e.mu.Unlock()
+	if !keep {
+		proc.stop()
+	}
+}
+
+// requestMove runs one position/go exchange
+func (proc *engineProcess) requestMove(p Position, moveTime time.Duration) (int, error) {
+	rules := p.Rules()
+	if err := proc.send(fmt.Sprintf("rules %d %d %d", rules.Rows, rules.Columns, rules.Connect)); err != nil {
+		return -1, err
+	}
+	if err := proc.send("position " + boardString(p)); err != nil {
+		return -1, err
+	}
+	// Round up: "go movetime 0" would leave the engine no time at all
+	ms := (moveTime + time.Millisecond - 1).Milliseconds()
+	if err := proc.send(fmt.Sprintf("go movetime %d", ms)); err != nil {
+		return -1, err
+	}
+
+	line, err := proc.expect("bestmove", moveTime+engineGrace)
+	if err != nil {
+		return -1, err
+	}
+	col, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "bestmove")))
+	if err != nil {
+		return -1, fmt.Errorf("bad reply %q", line)
+	}
+	col-- // protocol columns are 1-based
+	if !p.CanPlay(col) {
+		return -1, fmt.Errorf("illegal move %d", col+1)
+	}
+	return col, nil
+}
+
+// startEngine launches path and performs the c4i handshake. On failure the
+// process, if it started, is returned for the caller to stop.
+func startEngine(path string, args []string) (*engineProcess, error) {
+	cmd := exec.Command(path, args...)
+	// Don't let Wait hang on output pipes kept open by the engine's children
+	cmd.WaitDelay = engineGrace
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	proc := &engineProcess{
+		cmd:   cmd,
+		stdin: stdin,
+		lines: make(chan string, 64),
+		done:  make(chan struct{}),
+		id:    path,
+	}
+	go func() {
+		defer close(proc.lines)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			select {
+			case proc.lines <- strings.TrimSpace(scanner.Text()):
+			case <-proc.done:
+				return
+			}
+		}
+	}()
+
+	if err := proc.send("c4i"); err != nil {
+		return proc, err
+	}
+	for {
+		line, err := proc.next(engineStartTimeout)
+		if err != nil {
+			return proc, fmt.Errorf("handshake: %w", err)
+		}
+		if strings.HasPrefix(line, "id name ") {
+			proc.id = strings.TrimPrefix(line, "id name ")
+		}
+		if line == "c4iok" {
+			return proc, nil
+		}
+	}
+}
+
+// stop kills the engine process and waits for it and its reader to finish
+func (proc *engineProcess) stop() {
+	proc.send("quit")
+	proc.stdin.Close()
+	if proc.cmd.Process != nil {
+		proc.cmd.Process.Kill()
+	}
+	close(proc.done)
+	proc.cmd.Wait()
+	for range proc.lines {
+		// The reader closes lines once it sees done or the pipe closing
+	}
+}
+
+// send writes one command line to the engine
+func (proc *engineProcess) send(line string) error {
+	_, err := io.WriteString(proc.stdin, line+"\n")
+	return err
+}
+
+// expect skips output (e.g. "info" lines) until a line starting with prefix
+func (proc *engineProcess) expect(prefix string, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		line, err := proc.next(time.Until(deadline))
+		if err != nil {
+			return "", err
+		}
+		if strings.HasPrefix(line, prefix) {
+			return line, nil
+		}
+	}
+}
+
+// next reads one line of engine output
+func (proc *engineProcess) next(timeout time.Duration) (string, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case line, ok := <-proc.lines:
+		if !ok {
+			return "", fmt.Errorf("engine exited")
+		}
+		return line, nil
+	case <-timer.C:
+		return "", fmt.Errorf("no reply within %v", timeout)
+	}
+}
+
+// boardString encodes p for the engine protocol as a board string (see
+// FormatBoard): rows top to bottom separated by '/', 'x' for player 1, 'o'
+// for player 2, a number for a run of empty cells, then the side to move
+func boardString(p Position) string {
+	return p.String()
+}
@@ -0,0 +1,232 @@
+package game
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// helperEngine returns an EngineBot that runs this test binary as an
+// engine (see TestHelperEngine) behaving as mode says
+func helperEngine(t *testing.T, mode ...string) *EngineBot {
+	t.Setenv("GO_WANT_HELPER_ENGINE", "1")
+	e := NewEngineBot("helper", os.Args[0], append([]string{"-test.run=TestHelperEngine", "--"}, mode...)...)
+	t.Cleanup(func() { e.Close() })
+	return e
+}
+
+// mustParseMoves returns the position after moves
+func mustParseMoves(t *testing.T, moves string) Position {
+	t.Helper()
+	p, err := ParseMoves(moves)
+	if err != nil {
+		t.Fatalf("%q: %v", moves, err)
+	}
+	return p
+}
+
+// idleEngines returns how many of e's processes are waiting for a move
+func idleEngines(e *EngineBot) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.idle)
+}
+
+// TestHelperEngine isn't a real test: it is the engine run by helperEngine.
+// Modes:
+//
+//	play N          answer bestmove N to every go
+//	sleep D N       wait D before each answer
+//	hang-once FILE  ignore the first go of all, creating FILE; then play 2
+//	chatty          print info lines nobody reads, and never answer go
+//	exit            exit before the handshake
+func TestHelperEngine(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_ENGINE") != "1" {
+		return
+	}
+	args := os.Args
+	for len(args) > 0 && args[0] != "--" {
+		args = args[1:]
+	}
+	if len(args) < 2 || args[1] == "exit" {
+		os.Exit(1)
+	}
+	mode, args := args[1], args[2:]
+
+	reply := func(lines ...string) {
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+	}
+	in := bufio.NewScanner(os.Stdin)
+	for in.Scan() {
+		cmd := strings.Fields(in.Text())
+		if len(cmd) == 0 {
+			continue
+		}
+		switch cmd[0] {
+		case "c4i":
+			reply("id name helper", "id author tests", "c4iok")
+			if mode == "chatty" {
+				for i := 0; i < 1000; i++ {
+					reply(fmt.Sprintf("info line %d", i))
+				}
+			}
+		case "go":
+			switch mode {
+			case "play":
+				reply("info depth 1", "bestmove "+args[0])
+			case "sleep":
+				d, _ := time.ParseDuration(args[0])
+				time.Sleep(d)
+				reply("bestmove " + args[1])
+			case "hang-once":
+				if _, err := os.Stat(args[0]); err != nil {
+					os.WriteFile(args[0], nil, 0o644)
+					continue
+				}
+				reply("bestmove 2")
+			}
+		case "quit":
+			os.Exit(0)
+		}
+	}
+	os.Exit(0)
+}
+
+func TestEngineHandshake(t *testing.T) {
+	helperEngine(t) // sets up the environment for the helper
+
+	proc, err := startEngine(os.Args[0], []string{"-test.run=TestHelperEngine", "--", "play", "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proc.id != "helper" {
+		t.Errorf("engine id %q, want helper", proc.id)
+	}
+	proc.stop()
+
+	proc, err = startEngine(os.Args[0], []string{"-test.run=TestHelperEngine", "--", "exit"})
+	if err == nil {
+		t.Error("handshake with an engine that exited succeeded")
+	}
+	if proc != nil {
+		proc.stop()
+	}
+}
+
+func TestEngineMoves(t *testing.T) {
+	tests := []struct {
+		name  string
+		moves string
+		mode  []string
+		want  int  // -1 for the fallback bot's move
+		kept  bool // the process is kept for the next move
+	}{
+		{"valid bestmove", "4444", []string{"play", "2"}, 1, true},
+		{"last column", "4444", []string{"play", "7"}, 6, true},
+		{"column out of range", "4444", []string{"play", "9"}, -1, false},
+		{"full column", "444444", []string{"play", "4"}, -1, false},
+		{"not a number", "4444", []string{"play", "x"}, -1, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := helperEngine(t, tc.mode...)
+			p := mustParseMoves(t, tc.moves)
+			got := e.ChooseMove(p, time.Now().Add(time.Second))
+			if !p.CanPlay(got) {
+				t.Fatalf("illegal move %d", got)
+			}
+			if tc.want >= 0 && got != tc.want {
+				t.Errorf("got column %d, want %d", got, tc.want)
+			}
+			if kept := idleEngines(e) == 1; kept != tc.kept {
+				t.Errorf("process kept %v, want %v", kept, tc.kept)
+			}
+		})
+	}
+}
+
+func TestEngineTimeoutRestarts(t *testing.T) {
+	e := helperEngine(t, "hang-once", t.TempDir()+"/hung")
+	p := mustParseMoves(t, "")
+
+	start := time.Now()
+	got := e.ChooseMove(p, time.Now().Add(50*time.Millisecond))
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond+engineGrace+time.Second {
+		t.Errorf("timed out engine took %v", elapsed)
+	}
+	if !p.CanPlay(got) {
+		t.Fatalf("fallback played illegal move %d", got)
+	}
+	if idleEngines(e) != 0 {
+		t.Fatal("hung engine kept")
+	}
+
+	// The next request starts a new process, which answers
+	if got := e.ChooseMove(p, time.Now().Add(time.Second)); got != 1 {
+		t.Errorf("restarted engine: got column %d, want 1", got)
+	}
+	if idleEngines(e) != 1 {
+		t.Error("restarted engine not kept")
+	}
+}
+
+func TestEngineDeadlinePassed(t *testing.T) {
+	e := helperEngine(t, "play", "2")
+	p := mustParseMoves(t, "")
+	if got := e.ChooseMove(p, time.Now().Add(-time.Second)); !p.CanPlay(got) {
+		t.Fatalf("illegal move %d", got)
+	}
+	if idleEngines(e) != 0 {
+		t.Error("engine started for a deadline that had passed")
+	}
+}
+
+func TestEngineConcurrentGames(t *testing.T) {
+	e := helperEngine(t, "sleep", "300ms", "3")
+	p := mustParseMoves(t, "")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := e.ChooseMove(p, time.Now().Add(5*time.Second)); got != 2 {
+				t.Errorf("got column %d, want 2", got)
+			}
+		}()
+	}
+	wg.Wait()
+	// Had the second move waited for the first, one process would do
+	if n := idleEngines(e); n != 2 {
+		t.Errorf("%d processes after two simultaneous moves, want 2", n)
+	}
+}
+
+func TestEngineStopReleasesReader(t *testing.T) {
+	helperEngine(t)
+	proc, err := startEngine(os.Args[0], []string{"-test.run=TestHelperEngine", "--", "chatty"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Let the output fill the lines buffer so the reader blocks
+	for len(proc.lines) < cap(proc.lines) {
+		time.Sleep(time.Millisecond)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		proc.stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stop didn't return: reader still blocked")
+	}
+}
@@ -1,12 +1,31 @@
 package game
 
 func NewGame(p1, p2 string) *Game {
-	var board [6][7]int
+	return NewGameWithRules(p1, p2, ClassicRules)
+}
+
+// NewGameWithRules creates a game on an empty board sized for rules,
+// played as PopOut if rules.PopOut is set and classic otherwise
+func NewGameWithRules(p1, p2 string, rules Rules) *Game {
+	variant, _ := LookupVariant(DefaultVariant)
+	if rules.PopOut {
+		variant, _ = LookupVariant("popout")
+	}
+	return NewGameWithVariant(p1, p2, variant, rules)
+}
 
-	return &Game{
+// NewGameWithVariant creates a game of variant on a board sized for rules.
+// The variant decides whether pops are allowed, whatever rules.PopOut says.
+func NewGameWithVariant(p1, p2 string, variant Variant, rules Rules) *Game {
+	rules.PopOut = variant.Rules().PopOut
+	g := &Game{
 		Player1: p1,
 		Player2: p2,
 		Turn:    1,
-		Board:   board,
+		Board:   NewBoard(rules),
+		Rules:   rules,
+		Variant: variant,
 	}
+	variant.Setup(g)
+	return g
 }
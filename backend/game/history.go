@@ -0,0 +1,123 @@
+package game
+
+import (
+	"errors"
+	"time"
+)
+
+// MoveRecord is one entry in a game's move history
+type MoveRecord struct {
+	Player int `json:"player"`
+	Move
+	Time   time.Time `json:"timestamp"`
+	Result string    `json:"result"` // "OK", "WIN" or "DRAW", as returned by PlayMove
+
+	before snapshot // game state before the move, restored by Undo
+}
+
+// snapshot is the part of a Game that moves change
+type snapshot struct {
+	board          Board
+	turn           int
+	gameOver       bool
+	winner         int
+	winningLines   [][]Cell
+	endReason      EndReason
+	positionCounts map[string]int
+	powers         map[int][]PowerDisc
+}
+
+// takeSnapshot copies everything a move can change in g
+func takeSnapshot(g *Game) snapshot {
+	return snapshot{
+		board:          g.Board.Clone(),
+		turn:           g.Turn,
+		gameOver:       g.GameOver,
+		winner:         g.Winner,
+		winningLines:   g.WinningLines,
+		endReason:      g.EndReason,
+		positionCounts: copyPositionCounts(g.PositionCounts),
+		powers:         copyPowers(g.Powers),
+	}
+}
+
+// restore puts g back into the state s was taken from
+func (s snapshot) restore(g *Game) {
+	g.Board = s.board.Clone()
+	g.Turn = s.turn
+	g.GameOver = s.gameOver
+	g.Winner = s.winner
+	g.WinningLines = s.winningLines
+	g.EndReason = s.endReason
+	g.PositionCounts = copyPositionCounts(s.positionCounts)
+	g.Powers = copyPowers(s.powers)
+}
+
+// copyPositionCounts deep-copies PopOut repetition counts, keeping nil as nil
+func copyPositionCounts(counts map[string]int) map[string]int {
+	if counts == nil {
+		return nil
+	}
+	c := make(map[string]int, len(counts))
+	for k, v := range counts {
+		c[k] = v
+	}
+	return c
+}
+
+// copyPowers deep-copies Power Up discs, keeping nil as nil
+func copyPowers(powers map[int][]PowerDisc) map[int][]PowerDisc {
+	if powers == nil {
+		return nil
+	}
+	c := make(map[int][]PowerDisc, len(powers))
+	for player, discs := range powers {
+		c[player] = append([]PowerDisc(nil), discs...)
+	}
+	return c
+}
+
+// recordMove appends a played move to g.History. A new move ends any
+// chance to redo undone ones.
+func recordMove(g *Game, r MoveRecord) {
+	g.History = append(g.History, r)
+	g.undone = nil
+}
+
+// Undo takes back the last move in g.History, restoring the board, turn
+// and result from before it was played. The move can be replayed with Redo.
+func Undo(g *Game) error {
+	if len(g.History) == 0 {
+		return errors.New("Nothing to undo")
+	}
+	last := g.History[len(g.History)-1]
+	g.History = g.History[:len(g.History)-1]
+	stopClock(g) // clocks keep the time really spent
+	last.before.restore(g)
+	restartMoveClock(g)
+	g.undone = append(g.undone, last)
+	return nil
+}
+
+// Redo replays the last move taken back by Undo, keeping its original
+// record
+func Redo(g *Game) error {
+	if len(g.undone) == 0 {
+		return errors.New("Nothing to redo")
+	}
+	next := g.undone[len(g.undone)-1]
+	stopClock(g)
+	if result := applyMove(g, next.Move, next.Player); result != next.Result {
+		// only possible if the game was changed behind the history's back
+		return errors.New("Can't redo: " + result)
+	}
+	g.undone = g.undone[:len(g.undone)-1]
+	g.History = append(g.History, next)
+	passClock(g, next.Player)
+	return nil
+}
+
+// CanRedo reports whether Redo has a move to replay
+func CanRedo(g *Game) bool {
+	return len(g.undone) > 0
+}
@@ -0,0 +1,257 @@
+package game
+
+import "testing"
+
+// drawRows is a full board without four in a row, missing only the top
+// disc of column 6 (player 1's)
+var drawRows = [6]string{
+	"XOXOXO.",
+	"XOXOXOX",
+	"OXOXOXO",
+	"OXOXOXO",
+	"XOXOXOX",
+	"XOXOXOX",
+}
+
+func TestUndoRedo(t *testing.T) {
+	tests := []struct {
+		name    string
+		variant string
+		setup   func(t *testing.T, g *Game) // optional starting position
+		moves   []Move                      // played alternately from g.Turn
+		undo    int
+		redo    int
+		wantErr bool // from the last Undo or Redo
+
+		wantRows    [6]string
+		wantTurn    int
+		wantOver    bool
+		wantWinner  int
+		wantHistory int
+		wantRedo    bool
+	}{
+		{
+			name:     "nothing to undo",
+			undo:     1,
+			wantErr:  true,
+			wantRows: [6]string{".......", ".......", ".......", ".......", ".......", "......."},
+			wantTurn: 1,
+		},
+		{
+			name:     "undo one drop",
+			moves:    []Move{{Column: 3}},
+			undo:     1,
+			wantRows: [6]string{".......", ".......", ".......", ".......", ".......", "......."},
+			wantTurn: 1,
+			wantRedo: true,
+		},
+		{
+			name:        "undo two drops",
+			moves:       []Move{{Column: 3}, {Column: 3}, {Column: 2}},
+			undo:        2,
+			wantRows:    [6]string{".......", ".......", ".......", ".......", ".......", "...X..."},
+			wantTurn:    2,
+			wantHistory: 1,
+			wantRedo:    true,
+		},
+		{
+			name:        "undo a win reopens the game",
+			moves:       []Move{{Column: 0}, {Column: 1}, {Column: 0}, {Column: 1}, {Column: 0}, {Column: 1}, {Column: 0}},
+			undo:        1,
+			wantRows:    [6]string{".......", ".......", ".......", "XO.....", "XO.....", "XO....."},
+			wantTurn:    1,
+			wantHistory: 6,
+			wantRedo:    true,
+		},
+		{
+			name: "undo a draw reopens the game",
+			setup: func(t *testing.T, g *Game) {
+				g.Board = boardFromRows(t, drawRows)
+			},
+			moves:    []Move{{Column: 6}},
+			undo:     1,
+			wantRows: drawRows,
+			wantTurn: 1,
+			wantRedo: true,
+		},
+		{
+			name:        "redo replays the win",
+			moves:       []Move{{Column: 0}, {Column: 1}, {Column: 0}, {Column: 1}, {Column: 0}, {Column: 1}, {Column: 0}},
+			undo:        3,
+			redo:        3,
+			wantRows:    [6]string{".......", ".......", "X......", "XO.....", "XO.....", "XO....."},
+			wantTurn:    1,
+			wantOver:    true,
+			wantWinner:  1,
+			wantHistory: 7,
+		},
+		{
+			name: "redo replays the draw",
+			setup: func(t *testing.T, g *Game) {
+				g.Board = boardFromRows(t, drawRows)
+			},
+			moves:       []Move{{Column: 6}},
+			undo:        1,
+			redo:        1,
+			wantRows:    [6]string{"XOXOXOX", "XOXOXOX", "OXOXOXO", "OXOXOXO", "XOXOXOX", "XOXOXOX"},
+			wantTurn:    1,
+			wantOver:    true,
+			wantHistory: 1,
+		},
+		{
+			name:        "nothing to redo",
+			moves:       []Move{{Column: 3}},
+			redo:        1,
+			wantErr:     true,
+			wantRows:    [6]string{".......", ".......", ".......", ".......", ".......", "...X..."},
+			wantTurn:    2,
+			wantHistory: 1,
+		},
+		{
+			name:     "undo a pop",
+			variant:  "popout",
+			moves:    []Move{{Column: 0}, {Column: 1}, {Column: 0, Pop: true}},
+			undo:     1,
+			wantRows: [6]string{".......", ".......", ".......", ".......", ".......", "XO....."},
+			wantTurn: 1,
+			// the pop is undone but can still be redone
+			wantHistory: 2,
+			wantRedo:    true,
+		},
+		{
+			name:        "undo a double keeps the turn",
+			variant:     "powerup",
+			moves:       []Move{{Column: 3, Power: PowerDouble}},
+			undo:        1,
+			wantRows:    [6]string{".......", ".......", ".......", ".......", ".......", "......."},
+			wantTurn:    1,
+			wantHistory: 0,
+			wantRedo:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			variant, ok := LookupVariant(tt.variant)
+			if !ok {
+				t.Fatalf("unknown variant %q", tt.variant)
+			}
+			g := NewGameWithVariant("alice", "bob", variant, variant.Rules())
+			if tt.setup != nil {
+				tt.setup(t, g)
+			}
+
+			for i, m := range tt.moves {
+				if result := PlayMove(g, m, g.Turn); result != "OK" && result != "WIN" && result != "DRAW" {
+					t.Fatalf("move %d %+v: %s", i, m, result)
+				}
+			}
+
+			var err error
+			for i := 0; i < tt.undo; i++ {
+				err = Undo(g)
+			}
+			for i := 0; i < tt.redo; i++ {
+				err = Redo(g)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			want := boardFromRows(t, tt.wantRows)
+			for r := range want {
+				for c := range want[r] {
+					if g.Board[r][c] != want[r][c] {
+						t.Fatalf("board = %v, want %v", g.Board, want)
+					}
+				}
+			}
+			if g.Turn != tt.wantTurn {
+				t.Errorf("Turn = %d, want %d", g.Turn, tt.wantTurn)
+			}
+			if g.GameOver != tt.wantOver || g.Winner != tt.wantWinner {
+				t.Errorf("GameOver, Winner = %v, %d, want %v, %d", g.GameOver, g.Winner, tt.wantOver, tt.wantWinner)
+			}
+			if len(g.History) != tt.wantHistory {
+				t.Errorf("len(History) = %d, want %d", len(g.History), tt.wantHistory)
+			}
+			if CanRedo(g) != tt.wantRedo {
+				t.Errorf("CanRedo = %v, want %v", CanRedo(g), tt.wantRedo)
+			}
+		})
+	}
+}
+
+func TestHistoryRecordsMoves(t *testing.T) {
+	g := NewGame("alice", "bob")
+	moves := []int{0, 1, 0, 1, 0, 1, 0}
+	for _, col := range moves {
+		MakeMove(g, col, g.Turn)
+	}
+	MakeMove(g, 2, g.Turn) // rejected, the game is over
+
+	if len(g.History) != len(moves) {
+		t.Fatalf("len(History) = %d, want %d", len(g.History), len(moves))
+	}
+	for i, r := range g.History {
+		wantPlayer := 1 + i%2
+		wantResult := "OK"
+		if i == len(moves)-1 {
+			wantResult = "WIN"
+		}
+		if r.Player != wantPlayer || r.Column != moves[i] || r.Result != wantResult {
+			t.Errorf("History[%d] = player %d column %d %s, want player %d column %d %s",
+				i, r.Player, r.Column, r.Result, wantPlayer, moves[i], wantResult)
+		}
+		if r.Time.IsZero() || (i > 0 && r.Time.Before(g.History[i-1].Time)) {
+			t.Errorf("History[%d] timestamp %v out of order", i, r.Time)
+		}
+	}
+}
+
+func TestNewMoveClearsRedo(t *testing.T) {
+	g := NewGame("alice", "bob")
+	MakeMove(g, 3, 1)
+	MakeMove(g, 3, 2)
+	if err := Undo(g); err != nil {
+		t.Fatal(err)
+	}
+	MakeMove(g, 4, 2)
+
+	if CanRedo(g) {
+		t.Error("CanRedo after a new move, want the undone move discarded")
+	}
+	if err := Redo(g); err == nil {
+		t.Error("Redo succeeded after a new move")
+	}
+	if g.Board[5][4] != 2 || g.Board[4][3] != 0 {
+		t.Errorf("board = %v, want the new move and not the undone one", g.Board)
+	}
+}
+
+func TestUndoRestoresVariantState(t *testing.T) {
+	popout, _ := LookupVariant("popout")
+	g := NewGameWithVariant("alice", "bob", popout, popout.Rules())
+	start := len(g.PositionCounts)
+	MakeMove(g, 0, 1)
+	MakeMove(g, 1, 2)
+	if err := Undo(g); err != nil {
+		t.Fatal(err)
+	}
+	if err := Undo(g); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.PositionCounts) != start {
+		t.Errorf("PopOut position counts = %v after undoing every move, want the start only", g.PositionCounts)
+	}
+
+	powerup, _ := LookupVariant("powerup")
+	g = NewGameWithVariant("alice", "bob", powerup, powerup.Rules())
+	PlayMove(g, Move{Column: 2, Power: PowerAnvil}, 1)
+	if err := Undo(g); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Powers[1]) != len(powerDiscs) {
+		t.Errorf("Powers[1] = %v after undoing the anvil, want all %d discs back", g.Powers[1], len(powerDiscs))
+	}
+}
@@ -2,45 +2,154 @@ package game
 
 import (
 	"log"
+	"sync"
 	"time"
 )
 
-var WaitingPlayer string
-var ActiveGames = make(map[string]*Game)
-
-func FindMatch(username string) *Game {
-
-	// If someone is waiting → pair them
-	if WaitingPlayer != "" && WaitingPlayer != username {
-
-		var board [6][7]int
-		game := &Game{
-			ID:          GenerateGameID(),
-			Player1:     WaitingPlayer,
-			Player2:     username,
-			Turn:        1,
-			Board:       board,
-			GameOver:    false,
-			Winner:      0,
-			LastSeen:    make(map[string]time.Time),
-			Connections: make(map[string]bool),
+// The queue, private rooms and active games are shared by every
+// connection, so they are only touched with matchLock held. A goroutine
+// may take matchLock while holding a game's lock, never the other way
+// round.
+var (
+	queue       MatchQueue               // Players waiting for an opponent
+	activeGames = make(map[string]*Game) // username -> the game they are in
+	matchLock   sync.Mutex
+)
+
+// DefaultRating is the rating of a player who hasn't played a rated game
+const DefaultRating = 1500.0
+
+// matchWindow is how close in rating paired players must be: 100 points at
+// first, widening to 500 over 10 seconds, when the bot joins instead
+var matchWindow = RatingWindow{Initial: 100, PerSecond: 40, Max: 500, Timeout: 10 * time.Second}
+
+// matchInterval is how often waiting players are checked against each
+// other again as their windows widen
+const matchInterval = 250 * time.Millisecond
+
+// startMatching starts the background sweep of the queue and private
+// rooms, once
+var startMatching = sync.OnceFunc(func() {
+	go func() {
+		for now := range time.Tick(matchInterval) {
+			sweepQueue(now)
+			expireRooms(now)
 		}
+	}()
+})
+
+// MatchOptions is the game a player is queueing for. Players are only
+// paired when their options are equal.
+type MatchOptions struct {
+	Variant     string      // registered Variant name
+	Rules       Rules       // board size and win length
+	TimeControl TimeControl // clocks, untimed if zero
+}
 
-		ActiveGames[game.Player1] = game
-		ActiveGames[game.Player2] = game
+// newGame creates a game of the variant and rules in opts, ready to be
+// registered: it has an ID and its clock is running
+func (opts MatchOptions) newGame(p1, p2 string) *Game {
+	variant, ok := LookupVariant(opts.Variant)
+	if !ok {
+		log.Printf("Unknown variant %q, using %s", opts.Variant, DefaultVariant)
+		variant, _ = LookupVariant(DefaultVariant)
+	}
+	g := NewGameWithVariant(p1, p2, variant, opts.Rules)
+	g.ID = GenerateGameID()
+	g.LastSeen = make(map[string]time.Time)
+	g.Connections = make(map[string]bool)
+	StartClock(g, opts.TimeControl)
+	return g
+}
+
+// matchOptionsOf returns the options g was created with
+func matchOptionsOf(g *Game) MatchOptions {
+	return MatchOptions{Variant: VariantOf(g).Name(), Rules: g.Rules, TimeControl: g.TimeControl}
+}
 
-		WaitingPlayer = ""
-		return game
+// FindMatch pairs username with the longest-waiting player who asked for
+// the same game and is close enough in rating. If there is none, username
+// joins the back of the queue (leaving any place they already had) and
+// FindMatch returns nil; they are paired later as the rating windows widen.
+func FindMatch(username string, rating float64, opts MatchOptions) *Game {
+	startMatching()
+
+	matchLock.Lock()
+	defer matchLock.Unlock()
+
+	queue.remove(username)
+	e := queueEntry{username: username, rating: rating, opts: opts, joined: time.Now()}
+
+	// If someone suitable is waiting for the same game → pair them
+	if waiter, ok := queue.match(e, matchWindow, e.joined); ok {
+		return startMatch(waiter, e)
 	}
 
 	// Else wait
-	WaitingPlayer = username
+	queue.join(e)
 	return nil
 }
 
+// startMatch creates and registers the game between two players taken off
+// the queue, the longer-waiting one first. matchLock must be held.
+func startMatch(first, second queueEntry) *Game {
+	game := first.opts.newGame(first.username, second.username)
+	activeGames[game.Player1] = game
+	activeGames[game.Player2] = game
+	log.Printf("Matched %s (%.0f) with %s (%.0f) after %s", first.username, first.rating, second.username, second.rating, time.Since(first.joined).Round(time.Millisecond))
+	return game
+}
+
+// sweepQueue pairs waiting players whose windows have widened enough as of
+// now, longest waiting first, and gives the bot to those whose windows have
+// timed out
+func sweepQueue(now time.Time) {
+	matchLock.Lock()
+	defer matchLock.Unlock()
+
+	for paired := true; paired; {
+		paired = false
+		for _, e := range queue.waiting {
+			// Anyone older who fits e would have been paired with e already
+			if younger, ok := queue.match(e, matchWindow, now); ok {
+				queue.remove(e.username)
+				startMatch(e, younger)
+				paired = true
+				break
+			}
+		}
+	}
+
+	for _, e := range append([]queueEntry(nil), queue.waiting...) {
+		if e.bot != nil && now.Sub(e.joined) >= matchWindow.Timeout {
+			queue.remove(e.username)
+			startBotGame(e)
+		}
+	}
+}
+
+// CancelMatch takes username out of the queue, e.g. when their socket
+// closes. It reports false if they weren't waiting, which means they have
+// been matched (or given a bot) in the meantime.
+func CancelMatch(username string) bool {
+	matchLock.Lock()
+	defer matchLock.Unlock()
+	return queue.remove(username)
+}
+
+// QueuePosition returns username's place in the queue (1 is next in line,
+// 0 if they aren't waiting) and how many players are waiting
+func QueuePosition(username string) (position, waiting int) {
+	matchLock.Lock()
+	defer matchLock.Unlock()
+	return queue.position(username), queue.Len()
+}
+
 // FindGameByID finds a game by its ID
 func FindGameByID(gameID string) *Game {
-	for _, g := range ActiveGames {
+	matchLock.Lock()
+	defer matchLock.Unlock()
+	for _, g := range activeGames {
 		if g.ID == gameID {
 			return g
 		}
@@ -50,33 +159,52 @@ func FindGameByID(gameID string) *Game {
 
 // FindGameByUsername finds a game for a username (for reconnection)
 func FindGameByUsername(username string) *Game {
-	return ActiveGames[username]
+	matchLock.Lock()
+	defer matchLock.Unlock()
+	return activeGames[username]
 }
 
-func StartBotIfNoPlayer(username string) {
-	time.AfterFunc(10*time.Second, func() {
-		// Check if player is still waiting
-		if WaitingPlayer == username {
-			log.Printf("Bot joining game for player: %s", username)
-
-			var board [6][7]int
-			game := &Game{
-				ID:          GenerateGameID(),
-				Player1:     username,
-				Player2:     "BOT",
-				Turn:        1,
-				Board:       board,
-				GameOver:    false,
-				Winner:      0,
-				LastSeen:    make(map[string]time.Time),
-				Connections: make(map[string]bool),
-			}
+// BotOptions describes the bot a waiting player wants if nobody else joins
+type BotOptions struct {
+	Difficulty Difficulty
+	BotName    string // registered Bot to play, usually Difficulty.BotName(); empty to match the player's rating
+	Seat       Seat   // which turn the human takes
+	Practice   bool   // grant takebacks automatically
+}
 
-			ActiveGames[username] = game
-			WaitingPlayer = ""
-			log.Printf("Bot game created for %s (Game ID: %s)", username, game.ID)
-		}
-	})
+// StartBotIfNoPlayer arranges for username to play the requested bot if
+// they are still in the queue when their rating window times out. Without
+// a BotName they get the difficulty closest to their own rating.
+func StartBotIfNoPlayer(username string, opts BotOptions) {
+	matchLock.Lock()
+	defer matchLock.Unlock()
+	if e := queue.find(username); e != nil {
+		e.bot = &opts
+	}
+}
+
+// startBotGame creates and registers e's game against the bot. matchLock
+// must be held.
+func startBotGame(e queueEntry) *Game {
+	opts := *e.bot
+	if opts.BotName == "" {
+		opts.Difficulty = DifficultyForRating(e.rating)
+		opts.BotName = opts.Difficulty.BotName()
+	}
+	log.Printf("Bot joining game for player: %s", e.username)
+
+	game := e.opts.newGame(e.username, BotPlayerName)
+	game.Difficulty = opts.Difficulty
+	game.BotName = opts.BotName
+	game.BotPlayer = botPlayerFor(opts.Seat)
+	game.Practice = opts.Practice
+	if game.BotPlayer == 1 {
+		game.Player1, game.Player2 = BotPlayerName, e.username
+	}
+
+	activeGames[e.username] = game
+	log.Printf("Bot game created for %s (Game ID: %s, bot: %s as player %d, %s %s)", e.username, game.ID, opts.BotName, game.BotPlayer, game.Variant.Name(), game.Rules)
+	return game
 }
 
 // ForfeitGame marks the game as forfeited when a player disconnects
@@ -85,24 +213,24 @@ func ForfeitGame(g *Game, username string) {
 		return
 	}
 
-	g.GameOver = true
-
 	// Determine winner (opponent wins)
+	winner := 1
 	if username == g.Player1 {
-		g.Winner = 2
-	} else {
-		g.Winner = 1
+		winner = 2
 	}
+	endGame(g, winner, ReasonAbandonment)
 
 	log.Printf("Game forfeited by %s, winner: %d", username, g.Winner)
 }
 
-// RemoveGame removes a game from ActiveGames (cleanup after game ends)
+// RemoveGame removes a game from the active games (cleanup after game ends)
 func RemoveGame(gameID string) {
-	for username, g := range ActiveGames {
+	matchLock.Lock()
+	defer matchLock.Unlock()
+	for username, g := range activeGames {
 		if g != nil && g.ID == gameID {
-			delete(ActiveGames, username)
+			delete(activeGames, username)
 		}
 	}
-	log.Printf("Removed finished game %s from ActiveGames", gameID)
+	log.Printf("Removed finished game %s from active games", gameID)
 }
@@ -0,0 +1,311 @@
+package game
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// These tests share the matchmaker with everything else in the package, so
+// they don't run in parallel. Run them with -race: most of what they check
+// is that concurrent use doesn't race.
+
+var classicMatch = MatchOptions{Variant: DefaultVariant, Rules: ClassicRules}
+
+// resetMatchmaker empties the registry before and after a test
+func resetMatchmaker(t *testing.T) {
+	reset := func() {
+		matchLock.Lock()
+		queue = MatchQueue{}
+		rooms = make(map[string]*room)
+		activeGames = make(map[string]*Game)
+		matchLock.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+// playRandomly plays random legal moves as player until g is over, taking
+// g's lock for every look at the game
+func playRandomly(g *Game, player int, rng *rand.Rand) {
+	for {
+		g.Lock()
+		if g.GameOver {
+			g.Unlock()
+			return
+		}
+		if g.Turn == player {
+			PlayMove(g, Move{Column: rng.Intn(g.Rules.Columns)}, player)
+		}
+		g.Unlock()
+		time.Sleep(time.Duration(rng.Intn(50)) * time.Microsecond)
+	}
+}
+
+// checkGame checks that a finished game's record agrees with its board
+func checkGame(t *testing.T, g *Game) {
+	t.Helper()
+	g.Lock()
+	defer g.Unlock()
+
+	discs := 0
+	for _, row := range g.Board {
+		for _, cell := range row {
+			if cell != 0 {
+				discs++
+			}
+		}
+	}
+	if discs != len(g.History) {
+		t.Errorf("game %s: %d discs on the board after %d moves", g.ID, discs, len(g.History))
+	}
+	if !g.GameOver || g.EndReason == "" {
+		t.Errorf("game %s: over %v with reason %q", g.ID, g.GameOver, g.EndReason)
+	}
+}
+
+func TestFindMatchConcurrent(t *testing.T) {
+	resetMatchmaker(t)
+
+	const players = 200
+	games := make(chan *Game, players)
+	var wg sync.WaitGroup
+	for i := 0; i < players; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if g := FindMatch(name, DefaultRating, classicMatch); g != nil {
+				games <- g
+			}
+		}(fmt.Sprintf("player%d", i))
+	}
+	wg.Wait()
+	close(games)
+
+	seen := make(map[string]bool)
+	count := 0
+	for g := range games {
+		count++
+		for _, name := range []string{g.Player1, g.Player2} {
+			if seen[name] {
+				t.Errorf("%s was matched twice", name)
+			}
+			seen[name] = true
+			if got := FindGameByUsername(name); got != g {
+				t.Errorf("FindGameByUsername(%s) = %p, want %p", name, got, g)
+			}
+		}
+		if got := FindGameByID(g.ID); got == nil || got.ID != g.ID {
+			t.Errorf("FindGameByID(%s) = %v", g.ID, got)
+		}
+	}
+	if count != players/2 {
+		t.Errorf("%d games for %d players, want %d", count, players, players/2)
+	}
+	if _, waiting := QueuePosition(""); waiting != 0 {
+		t.Errorf("%d players still waiting after everyone was paired", waiting)
+	}
+}
+
+func TestStartBotIfNoPlayerConcurrent(t *testing.T) {
+	resetMatchmaker(t)
+
+	// Players arrive while the queue is swept as if their windows had long
+	// timed out, so some are paired with each other and the rest get a
+	// bot, but nobody gets both
+	later := time.Now().Add(time.Hour)
+	const players = 100
+	var wg sync.WaitGroup
+	for i := 0; i < players; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if FindMatch(name, DefaultRating, classicMatch) == nil {
+				StartBotIfNoPlayer(name, BotOptions{Difficulty: DifficultyBeginner, BotName: DifficultyBeginner.BotName()})
+			}
+			sweepQueue(later)
+		}(fmt.Sprintf("player%d", i))
+	}
+	wg.Wait()
+	sweepQueue(later) // anyone who asked for the bot after the last sweep
+
+	for i := 0; i < players; i++ {
+		name := fmt.Sprintf("player%d", i)
+		g := FindGameByUsername(name)
+		if g == nil {
+			t.Errorf("%s never got a game", name)
+			continue
+		}
+		g.Lock()
+		if g.Player1 != name && g.Player2 != name {
+			t.Errorf("%s registered in %s vs %s", name, g.Player1, g.Player2)
+		}
+		g.Unlock()
+	}
+}
+
+func TestConcurrentMovesAndDisconnects(t *testing.T) {
+	resetMatchmaker(t)
+
+	const pairs = 50
+	var games []*Game
+	for i := 0; i < pairs; i++ {
+		FindMatch(fmt.Sprintf("a%d", i), DefaultRating, classicMatch)
+		games = append(games, FindMatch(fmt.Sprintf("b%d", i), DefaultRating, classicMatch))
+	}
+
+	var wg sync.WaitGroup
+	for i, g := range games {
+		wg.Add(4)
+		for player := 1; player <= 2; player++ {
+			go func(g *Game, player int, seed int64) {
+				defer wg.Done()
+				playRandomly(g, player, rand.New(rand.NewSource(seed)))
+			}(g, player, int64(2*i+player))
+		}
+
+		// Half the games lose a player part way through
+		go func(g *Game, forfeit bool) {
+			defer wg.Done()
+			if !forfeit {
+				return
+			}
+			time.Sleep(time.Duration(rand.Intn(500)) * time.Microsecond)
+			g.Lock()
+			g.Connections[g.Player2] = false
+			g.LastSeen[g.Player2] = time.Now()
+			ForfeitGame(g, g.Player2)
+			g.Unlock()
+		}(g, i%2 == 0)
+
+		// Meanwhile other connections look the game up and clean up
+		go func(g *Game) {
+			defer wg.Done()
+			for {
+				if FindGameByID(g.ID) == nil {
+					return
+				}
+				g.Lock()
+				over := g.GameOver
+				g.Unlock()
+				if over {
+					RemoveGame(g.ID)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	for _, g := range games {
+		checkGame(t, g)
+		if FindGameByUsername(g.Player1) != nil || FindGameByUsername(g.Player2) != nil {
+			t.Errorf("game %s still registered after RemoveGame", g.ID)
+		}
+	}
+}
+
+func TestRematchConcurrent(t *testing.T) {
+	resetMatchmaker(t)
+
+	FindMatch("alice", DefaultRating, classicMatch)
+	g := FindMatch("bob", DefaultRating, classicMatch)
+	g.Lock()
+	g.Connections["alice"], g.Connections["bob"] = true, true
+	Resign(g, 2)
+	if err := RequestRematch(g, 2); err != nil {
+		t.Fatal(err)
+	}
+	g.Unlock()
+
+	// Both players race to accept (only alice may) while others follow the
+	// series and look the players up
+	var wg sync.WaitGroup
+	started := make(chan *Game, 20)
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func(player int) {
+			defer wg.Done()
+			g.Lock()
+			defer g.Unlock()
+			if next, err := AcceptRematch(g, player); err == nil {
+				started <- next
+			}
+		}(1 + i%2)
+		go func() {
+			defer wg.Done()
+			Latest(g)
+		}()
+		go func() {
+			defer wg.Done()
+			FindGameByUsername("alice")
+		}()
+	}
+	wg.Wait()
+	close(started)
+
+	if len(started) != 1 {
+		t.Fatalf("%d rematches started, want 1", len(started))
+	}
+	next := <-started
+	if got := Latest(g); got != next {
+		t.Errorf("Latest = %p, want the rematch %p", got, next)
+	}
+	if FindGameByUsername("alice") != next || FindGameByUsername("bob") != next {
+		t.Error("players not registered in the rematch")
+	}
+	next.Lock()
+	defer next.Unlock()
+	if next.Player1 != "bob" || next.Player2 != "alice" {
+		t.Errorf("rematch is %s vs %s, want colours swapped", next.Player1, next.Player2)
+	}
+	if want := (Series{Games: 1, Wins: map[string]int{"alice": 1}}); fmt.Sprint(next.Series) != fmt.Sprint(want) {
+		t.Errorf("series = %+v, want %+v", next.Series, want)
+	}
+}
+
+func TestRematchNeedsBothPlayers(t *testing.T) {
+	tests := []struct {
+		name  string
+		leave func(t *testing.T, g *Game) // what bob does after asking
+	}{
+		{"disconnected", func(t *testing.T, g *Game) { g.Connections["bob"] = false }},
+		{"queued", func(t *testing.T, g *Game) {
+			matchLock.Lock()
+			queue.join(queueEntry{username: "bob", rating: DefaultRating, opts: classicMatch, joined: time.Now()})
+			matchLock.Unlock()
+		}},
+		{"hosting a room", func(t *testing.T, g *Game) { CreateRoom("bob", classicMatch) }},
+		{"in another game", func(t *testing.T, g *Game) {
+			FindMatch("carol", DefaultRating, classicMatch)
+			if FindMatch("bob", DefaultRating, classicMatch) == nil {
+				t.Fatal("bob not matched with carol")
+			}
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resetMatchmaker(t)
+			FindMatch("alice", DefaultRating, classicMatch)
+			g := FindMatch("bob", DefaultRating, classicMatch)
+			g.Connections["alice"], g.Connections["bob"] = true, true
+			Resign(g, 2)
+			if err := RequestRematch(g, 2); err != nil {
+				t.Fatal(err)
+			}
+			tc.leave(t, g)
+			current := FindGameByUsername("bob")
+
+			if next, err := AcceptRematch(g, 1); err == nil {
+				t.Fatalf("rematch %s started without bob", next.ID)
+			}
+			if g.RematchRequest != 0 {
+				t.Error("refused request still pending")
+			}
+			if FindGameByUsername("bob") != current {
+				t.Error("bob's current game was replaced")
+			}
+		})
+	}
+}
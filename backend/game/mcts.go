@@ -0,0 +1,176 @@
+package game
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// MCTSConfig controls the Monte Carlo Tree Search bot. The search stops at
+// whichever of Playouts or TimeLimit is reached first; zero means no limit,
+// but at least one of them must be set.
+type MCTSConfig struct {
+	Playouts    int
+	TimeLimit   time.Duration
+	Exploration float64 // UCT exploration constant, sqrt(2) if zero
+}
+
+// DefaultMCTSConfig is what the bot uses in live games
+var DefaultMCTSConfig = MCTSConfig{
+	Playouts:  50000,
+	TimeLimit: time.Second,
+}
+
+// mctsNode is one position in the search tree
+type mctsNode struct {
+	parent   *mctsNode
+	children []*mctsNode
+	untried  []int
+	move     int     // column played to reach this node
+	player   int     // player who played move
+	visits   float64 // playouts through this node
+	score    float64 // wins for player, draws count half
+	winner   int     // set when the game is over at this node: player, or -1 for a draw
+}
+
+// MCTSMove picks a column for the player to move in p with UCT search
+func MCTSMove(p Position, cfg MCTSConfig) int {
+	if cfg.Exploration == 0 {
+		cfg.Exploration = math.Sqrt2
+	}
+	if cfg.Playouts == 0 && cfg.TimeLimit == 0 {
+		cfg.Playouts = DefaultMCTSConfig.Playouts
+	}
+
+	// Don't spend the budget on a move we can see directly
+	for c := 0; c < p.Columns(); c++ {
+		if p.IsWinningMove(c) {
+			return c
+		}
+	}
+
+	root := &mctsNode{untried: legalColumns(p), player: opponent(p.Turn())}
+	if len(root.untried) == 0 {
+		return -1
+	}
+
+	var deadline time.Time
+	if cfg.TimeLimit > 0 {
+		deadline = time.Now().Add(cfg.TimeLimit)
+	}
+
+	for i := 0; cfg.Playouts == 0 || i < cfg.Playouts; i++ {
+		if !deadline.IsZero() && i > 0 && i%256 == 0 && time.Now().After(deadline) {
+			break
+		}
+
+		node := root
+		pos := p
+
+		// Selection: walk down fully expanded nodes by UCT
+		for len(node.untried) == 0 && len(node.children) > 0 {
+			node = node.selectChild(cfg.Exploration)
+			pos.Play(node.move)
+		}
+
+		// Expansion: add one unexplored move
+		if len(node.untried) > 0 && node.winner == 0 {
+			node = node.expand(&pos)
+		}
+
+		// Simulation: play randomly to the end
+		winner := node.winner
+		if winner == 0 {
+			winner = randomPlayout(pos)
+		}
+
+		// Backpropagation
+		for n := node; n != nil; n = n.parent {
+			n.visits++
+			if winner == n.player {
+				n.score++
+			} else if winner == -1 {
+				n.score += 0.5
+			}
+		}
+	}
+
+	best := root.children[0]
+	for _, child := range root.children[1:] {
+		if child.visits > best.visits {
+			best = child
+		}
+	}
+	return best.move
+}
+
+// selectChild returns the child with the highest UCT value
+func (n *mctsNode) selectChild(exploration float64) *mctsNode {
+	logVisits := math.Log(n.visits)
+	var best *mctsNode
+	bestValue := math.Inf(-1)
+	for _, child := range n.children {
+		value := child.score/child.visits + exploration*math.Sqrt(logVisits/child.visits)
+		if value > bestValue {
+			bestValue = value
+			best = child
+		}
+	}
+	return best
+}
+
+// expand plays a random untried move on pos and returns the new child
+func (n *mctsNode) expand(pos *Position) *mctsNode {
+	i := rand.Intn(len(n.untried))
+	col := n.untried[i]
+	n.untried[i] = n.untried[len(n.untried)-1]
+	n.untried = n.untried[:len(n.untried)-1]
+
+	child := &mctsNode{parent: n, move: col, player: pos.Turn()}
+	if pos.IsWinningMove(col) {
+		child.winner = child.player
+	}
+	pos.Play(col)
+	if child.winner == 0 {
+		if pos.IsFull() {
+			child.winner = -1
+		} else {
+			child.untried = legalColumns(*pos)
+		}
+	}
+
+	n.children = append(n.children, child)
+	return child
+}
+
+// randomPlayout plays random moves from p, taking any immediate win, and
+// returns the winner or -1 for a draw
+func randomPlayout(p Position) int {
+	for !p.IsFull() {
+		var cols [maxColumns]int
+		n := 0
+		for c := 0; c < p.Columns(); c++ {
+			if !p.CanPlay(c) {
+				continue
+			}
+			if p.IsWinningMove(c) {
+				return p.Turn()
+			}
+			cols[n] = c
+			n++
+		}
+		p.Play(cols[rand.Intn(n)])
+	}
+	return -1
+}
+
+// legalColumns lists the columns that still have room
+func legalColumns(p Position) []int {
+	cols := make([]int, 0, p.Columns())
+	for c := 0; c < p.Columns(); c++ {
+		if p.CanPlay(c) {
+			cols = append(cols, c)
+		}
+	}
+	return cols
+}
@@ -0,0 +1,131 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+// benchMCTSConfig keeps benchmark games quick while still beating the heuristic
+var benchMCTSConfig = MCTSConfig{Playouts: 2000}
+
+// playMCTSVsHeuristic plays one game and returns the winner (0 for a draw)
+func playMCTSVsHeuristic(mctsPlayer int) int {
+	g := NewGame("mcts", "heuristic")
+	if mctsPlayer == 2 {
+		g = NewGame("heuristic", "mcts")
+	}
+
+	for !g.GameOver {
+		var col int
+		if g.Turn == mctsPlayer {
+			col = MCTSMove(GamePosition(g), benchMCTSConfig)
+		} else {
+			col = HeuristicMove(g)
+		}
+		MakeMove(g, col, g.Turn)
+	}
+	return g.Winner
+}
+
+func TestMCTSTakesWinAndBlocks(t *testing.T) {
+	win := boardFromRows(t, [6]string{
+		".......",
+		".......",
+		".......",
+		".......",
+		"XX.....",
+		"XXOOO..",
+	})
+	if col := MCTSMove(PositionFromBoard(ClassicRules, win, 2), benchMCTSConfig); col != 5 {
+		t.Errorf("MCTSMove = %d, want winning column 5", col)
+	}
+
+	block := boardFromRows(t, [6]string{
+		".......",
+		".......",
+		".......",
+		"X......",
+		"X......",
+		"X..OO..",
+	})
+	if col := MCTSMove(PositionFromBoard(ClassicRules, block, 2), benchMCTSConfig); col != 0 {
+		t.Errorf("MCTSMove = %d, want block in column 0", col)
+	}
+}
+
+func TestMCTSRespectsTimeLimit(t *testing.T) {
+	start := time.Now()
+	MCTSMove(NewPosition(), MCTSConfig{TimeLimit: 50 * time.Millisecond})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("MCTSMove took %v with a 50ms limit", elapsed)
+	}
+}
+
+func TestMCTSBotPassedDeadline(t *testing.T) {
+	// With the deadline gone the bot answers at once rather than running
+	// every playout, and still takes a win it can see
+	bot := mctsBot{config: DefaultMCTSConfig}
+	win := boardFromRows(t, [6]string{
+		".......",
+		".......",
+		".......",
+		".......",
+		"XX.....",
+		"XXOOO..",
+	})
+	for _, tc := range []struct {
+		name string
+		p    Position
+		want int // -1 for any legal column
+	}{
+		{"empty board", NewPosition(), -1},
+		{"winning move", PositionFromBoard(ClassicRules, win, 2), 5},
+	} {
+		start := time.Now()
+		col := bot.ChooseMove(tc.p, start.Add(-time.Second))
+		if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+			t.Errorf("%s: the bot took %v past its deadline", tc.name, elapsed)
+		}
+		if !tc.p.CanPlay(col) || (tc.want != -1 && col != tc.want) {
+			t.Errorf("%s: ChooseMove = %d, want %d", tc.name, col, tc.want)
+		}
+	}
+}
+
+// BenchmarkMCTSVsHeuristic plays MCTS against HeuristicMove, alternating
+// who moves first, and reports MCTS's score (win 1, draw 0.5) per game
+func BenchmarkMCTSVsHeuristic(b *testing.B) {
+	score := 0.0
+	for i := 0; i < b.N; i++ {
+		mctsPlayer := i%2 + 1
+		switch playMCTSVsHeuristic(mctsPlayer) {
+		case mctsPlayer:
+			score++
+		case 0:
+			score += 0.5
+		}
+	}
+	b.ReportMetric(score/float64(b.N), "score/game")
+}
+
+func BenchmarkMCTSMove(b *testing.B) {
+	p, _ := ParseMoves("4453")
+	for i := 0; i < b.N; i++ {
+		MCTSMove(p, benchMCTSConfig)
+	}
+}
+
+func BenchmarkHeuristicMove(b *testing.B) {
+	g := NewGame("a", "b")
+	g.Board = boardFromRows(b, [6]string{
+		".......",
+		".......",
+		".......",
+		".......",
+		"...X...",
+		"..OXO..",
+	})
+	for i := 0; i < b.N; i++ {
+		HeuristicMove(g)
+	}
+}
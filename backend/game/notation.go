@@ -0,0 +1,218 @@
+package game
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Text notation for games and positions.
+//
+// A move sequence lists the columns played from the empty board as 1-based
+// digits, e.g. "4453". Boards wider than 9 columns use 'a', 'b' and 'c' for
+// columns 10 to 12.
+//
+// A board string is FEN-like: rows from top to bottom separated by '/',
+// 'x' for player 1, 'o' for player 2 and a number for a run of empty
+// cells, then a space and the side to move, e.g. "7/7/7/7/3o3/2oxx2 x"
+// after "4453".
+
+// moveDigits are the column symbols of a move sequence, column 0 first
+const moveDigits = "123456789abc"
+
+// FormatMoves writes 0-based columns as a move sequence, e.g. "4453" for
+// []int{3, 3, 4, 2}
+func FormatMoves(columns []int) string {
+	var b strings.Builder
+	for _, col := range columns {
+		b.WriteByte(moveDigits[col])
+	}
+	return b.String()
+}
+
+// GameMoves writes g's history as a move sequence. It reports false for
+// games the notation can't describe: ones with pops or power discs, or
+// whose variant doesn't start from the empty board.
+func GameMoves(g *Game) (string, bool) {
+	columns := make([]int, 0, len(g.History))
+	for _, r := range g.History {
+		if r.Pop || r.Power != "" {
+			return "", false
+		}
+		columns = append(columns, r.Column)
+	}
+	// with only drops, a game started from the empty board has one disc
+	// per move
+	discs := 0
+	for _, row := range g.Board {
+		for _, cell := range row {
+			if cell != 0 {
+				discs++
+			}
+		}
+	}
+	if discs != len(columns) {
+		return "", false
+	}
+	return FormatMoves(columns), true
+}
+
+// ParseMoves replays a sequence of 1-based column digits (e.g. "4453")
+// from the empty classic board
+func ParseMoves(moves string) (Position, error) {
+	return ParseMovesWithRules(ClassicRules, moves)
+}
+
+// ParseMovesWithRules replays a move sequence from the empty board of rules.
+// A winning move is rejected, so the result is always an undecided position
+// the solver can score. ReplayMoves reads the record of a finished game.
+func ParseMovesWithRules(rules Rules, moves string) (Position, error) {
+	p := NewPositionWithRules(rules)
+	for i, ch := range moves {
+		col := strings.IndexRune(moveDigits, ch)
+		if col < 0 || col >= rules.Columns {
+			return p, fmt.Errorf("move %d: invalid column %q", i+1, ch)
+		}
+		if !p.CanPlay(col) {
+			return p, fmt.Errorf("move %d: column %c is full", i+1, ch)
+		}
+		if p.IsWinningMove(col) {
+			return p, fmt.Errorf("move %d: game already won", i+1)
+		}
+		p.Play(col)
+	}
+	return p, nil
+}
+
+// ReplayMoves plays a move sequence, such as GameMoves writes, into the new
+// game g, so the record of a finished game reads back with its result. The
+// last move may end the game; any move after that is rejected.
+func ReplayMoves(g *Game, moves string) error {
+	for i, ch := range moves {
+		col := strings.IndexRune(moveDigits, ch)
+		if col < 0 || col >= g.Rules.Columns {
+			return fmt.Errorf("move %d: invalid column %q", i+1, ch)
+		}
+		switch result := MakeMove(g, col, g.Turn); result {
+		case "OK", "WIN", "DRAW":
+		default:
+			return fmt.Errorf("move %d: %s", i+1, result)
+		}
+	}
+	return nil
+}
+
+// FormatBoard writes board and the player to move as a board string
+func FormatBoard(board Board, turn int) string {
+	var b strings.Builder
+	for row := 0; row < board.Rows(); row++ {
+		if row > 0 {
+			b.WriteByte('/')
+		}
+		empty := 0
+		for col := 0; col < board.Columns(); col++ {
+			if board[row][col] == 0 {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				b.WriteString(strconv.Itoa(empty))
+				empty = 0
+			}
+			if board[row][col] == 1 {
+				b.WriteByte('x')
+			} else {
+				b.WriteByte('o')
+			}
+		}
+		if empty > 0 {
+			b.WriteString(strconv.Itoa(empty))
+		}
+	}
+	if turn == 1 {
+		b.WriteString(" x")
+	} else {
+		b.WriteString(" o")
+	}
+	return b.String()
+}
+
+// ParseBoard reads a board string, returning the board and the player to
+// move. The board's size comes from the string; discs must rest on the
+// bottom row or on another disc.
+func ParseBoard(s string) (Board, int, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return nil, 0, fmt.Errorf("want \"<rows> <side to move>\", got %q", s)
+	}
+
+	var turn int
+	switch fields[1] {
+	case "x":
+		turn = 1
+	case "o":
+		turn = 2
+	default:
+		return nil, 0, fmt.Errorf("side to move must be x or o, got %q", fields[1])
+	}
+
+	var board Board
+	for i, text := range strings.Split(fields[0], "/") {
+		var row []int
+		for j := 0; j < len(text); j++ {
+			switch ch := text[j]; {
+			case ch == 'x':
+				row = append(row, 1)
+			case ch == 'o':
+				row = append(row, 2)
+			case ch >= '1' && ch <= '9':
+				end := j + 1
+				for end < len(text) && text[end] >= '0' && text[end] <= '9' {
+					end++
+				}
+				n, _ := strconv.Atoi(text[j:end])
+				if n > maxColumns {
+					return nil, 0, fmt.Errorf("row %d: too many empty cells", i+1)
+				}
+				row = append(row, make([]int, n)...)
+				j = end - 1
+			default:
+				return nil, 0, fmt.Errorf("row %d: invalid character %q", i+1, ch)
+			}
+		}
+		if len(row) == 0 {
+			return nil, 0, fmt.Errorf("row %d is empty", i+1)
+		}
+		if len(board) > 0 && len(row) != board.Columns() {
+			return nil, 0, fmt.Errorf("row %d has %d cells, want %d", i+1, len(row), board.Columns())
+		}
+		board = append(board, row)
+	}
+
+	for row := 0; row < board.Rows()-1; row++ {
+		for col := 0; col < board.Columns(); col++ {
+			if board[row][col] != 0 && board[row+1][col] == 0 {
+				return nil, 0, fmt.Errorf("row %d: disc in column %d is floating", row+1, col+1)
+			}
+		}
+	}
+	return board, turn, nil
+}
+
+// ParsePosition reads a board string for a board of rules, e.g. to feed a
+// pasted position to the solver
+func ParsePosition(rules Rules, s string) (Position, error) {
+	board, turn, err := ParseBoard(s)
+	if err != nil {
+		return Position{}, err
+	}
+	if board.Rows() != rules.Rows || board.Columns() != rules.Columns {
+		return Position{}, fmt.Errorf("board is %dx%d, want %dx%d", board.Rows(), board.Columns(), rules.Rows, rules.Columns)
+	}
+	return PositionFromBoard(rules, board, turn), nil
+}
+
+// String writes the position as a board string
+func (p Position) String() string {
+	return FormatBoard(p.Board(), p.Turn())
+}
@@ -0,0 +1,218 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMovesRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules Rules
+		moves string
+	}{
+		{"empty classic board", ClassicRules, ""},
+		{"classic opening", ClassicRules, "4453"},
+		{"full column", ClassicRules, "444444"},
+		{"every column", ClassicRules, "1234567"},
+		{"7x8", Rules{Rows: 7, Columns: 8, Connect: 4}, "81818"},
+		{"6x9 connect 5", Rules{Rows: 6, Columns: 9, Connect: 5}, "5599112"},
+		{"4x12 letters", Rules{Rows: 4, Columns: 12, Connect: 4}, "abc1ab"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := ParseMovesWithRules(tc.rules, tc.moves)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Play the same moves in a game and write them back
+			g := NewGameWithRules("a", "b", tc.rules)
+			var columns []int
+			for _, ch := range tc.moves {
+				col := indexOfMove(ch)
+				if result := MakeMove(g, col, g.Turn); result != "OK" {
+					t.Fatalf("move %c: %s", ch, result)
+				}
+				columns = append(columns, col)
+			}
+			if got := FormatMoves(columns); got != tc.moves {
+				t.Errorf("FormatMoves = %q", got)
+			}
+			if got, ok := GameMoves(g); !ok || got != tc.moves {
+				t.Errorf("GameMoves = %q, %v", got, ok)
+			}
+			if got, want := p.String(), FormatBoard(g.Board, g.Turn); got != want {
+				t.Errorf("parsed position %q, game board %q", got, want)
+			}
+		})
+	}
+}
+
+// indexOfMove returns the 0-based column of a move sequence symbol
+func indexOfMove(ch rune) int {
+	for i, d := range moveDigits {
+		if d == ch {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestFinishedGameRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		rules  Rules
+		moves  string
+		winner int
+		reason EndReason
+	}{
+		{"vertical win", ClassicRules, "1212121", 1, ReasonConnect4},
+		{"player 2 wins", ClassicRules, "71212131", 2, ReasonConnect4},
+		{"full board", ClassicRules, "121212343434565656777777212121434343656565", 0, ReasonBoardFull},
+		{"6x9 connect 5", rules6x9, "998877665", 1, ReasonConnect4},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewGameWithRules("a", "b", tc.rules)
+			if err := ReplayMoves(g, tc.moves); err != nil {
+				t.Fatal(err)
+			}
+			if !g.GameOver || g.Winner != tc.winner || g.EndReason != tc.reason {
+				t.Fatalf("over %v, winner %d by %s, want %d by %s", g.GameOver, g.Winner, g.EndReason, tc.winner, tc.reason)
+			}
+			moves, ok := GameMoves(g)
+			if !ok || moves != tc.moves {
+				t.Errorf("GameMoves = %q, %v", moves, ok)
+			}
+
+			// What GameMoves writes reads back as the same game
+			replayed := NewGameWithRules("a", "b", tc.rules)
+			if err := ReplayMoves(replayed, moves); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(replayed.Board, g.Board) || replayed.Winner != g.Winner || !reflect.DeepEqual(replayed.WinningLines, g.WinningLines) {
+				t.Errorf("replayed %s, winner %d, want %s, winner %d", FormatBoard(replayed.Board, replayed.Turn), replayed.Winner, FormatBoard(g.Board, g.Turn), g.Winner)
+			}
+		})
+	}
+
+	// Nothing may follow the end of the game
+	for _, moves := range []string{"12121214", "1212121x"} {
+		if err := ReplayMoves(NewGame("a", "b"), moves); err == nil {
+			t.Errorf("%q replayed", moves)
+		}
+	}
+}
+
+func TestGameMovesRejectsPops(t *testing.T) {
+	g := NewGameWithRules("a", "b", Rules{Rows: 6, Columns: 7, Connect: 4, PopOut: true})
+	for _, m := range []Move{{Column: 0}, {Column: 1}, {Column: 0, Pop: true}} {
+		if result := PlayMove(g, m, g.Turn); result != "OK" {
+			t.Fatalf("%+v: %s", m, result)
+		}
+	}
+	if moves, ok := GameMoves(g); ok {
+		t.Errorf("GameMoves = %q for a game with a pop", moves)
+	}
+
+	// The board after the pop still has a board string
+	s := FormatBoard(g.Board, g.Turn)
+	if s != "7/7/7/7/7/1o5 o" {
+		t.Errorf("FormatBoard = %q", s)
+	}
+	board, turn, err := ParseBoard(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(board, g.Board) || turn != g.Turn {
+		t.Errorf("ParseBoard(%q) = %v, %d", s, board, turn)
+	}
+}
+
+func TestBoardRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules Rules
+		board string
+	}{
+		{"empty", ClassicRules, "7/7/7/7/7/7 x"},
+		{"after 4453", ClassicRules, "7/7/7/7/3o3/2oxx2 x"},
+		{"full column", ClassicRules, "3o3/3x3/3o3/3x3/3o3/3x3 x"},
+		{"full bottom row", ClassicRules, "7/7/7/7/7/xoxoxox o"},
+		{"runs on both sides", ClassicRules, "7/7/7/7/o5x/x5o x"},
+		{"after a pop", Rules{Rows: 6, Columns: 7, Connect: 4, PopOut: true}, "7/7/7/7/o6/o1x4 x"},
+		{"7x8", Rules{Rows: 7, Columns: 8, Connect: 4}, "8/8/8/8/8/8/7x o"},
+		{"6x9", Rules{Rows: 6, Columns: 9, Connect: 5}, "9/9/9/9/4o4/xo2x3o x"},
+		{"12 columns", Rules{Rows: 4, Columns: 12, Connect: 4}, "12/12/12/11x o"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			board, turn, err := ParseBoard(tc.board)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := FormatBoard(board, turn); got != tc.board {
+				t.Errorf("FormatBoard(ParseBoard) = %q", got)
+			}
+			p, err := ParsePosition(tc.rules, tc.board)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.String(); got != tc.board {
+				t.Errorf("ParsePosition(...).String() = %q", got)
+			}
+		})
+	}
+}
+
+func TestParseMovesRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules Rules
+		moves string
+	}{
+		{"column past the board", ClassicRules, "48"},
+		{"column zero", ClassicRules, "0"},
+		{"not a column", ClassicRules, "4z"},
+		{"letter on a narrow board", Rules{Rows: 7, Columns: 8, Connect: 4}, "9"},
+		{"overfull column", ClassicRules, "4444444"},
+		{"overfull short column", Rules{Rows: 4, Columns: 12, Connect: 4}, "aaaaa"},
+		{"move after a win", ClassicRules, "1212121"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseMovesWithRules(tc.rules, tc.moves); err == nil {
+				t.Errorf("%q accepted", tc.moves)
+			}
+		})
+	}
+}
+
+func TestParseBoardRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		board string
+	}{
+		{"no side to move", "7/7/7/7/7/7"},
+		{"bad side to move", "7/7/7/7/7/7 y"},
+		{"short row", "7/7/7/7/7/6 x"},
+		{"long row", "7/7/7/7/7/4xoxo o"},
+		{"empty row", "7/7//7/7/7 x"},
+		{"bad character", "7/7/7/7/7/3z3 x"},
+		{"too many empty cells", "13/13/13/13 x"},
+		{"floating disc", "7/7/7/7/3x3/7 o"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, _, err := ParseBoard(tc.board); err == nil {
+				t.Errorf("%q accepted", tc.board)
+			}
+		})
+	}
+
+	// A good board string of the wrong size for the rules
+	if _, err := ParsePosition(ClassicRules, "8/8/8/8/8/8/8 x"); err == nil {
+		t.Error("7x8 board accepted for classic rules")
+	}
+}
@@ -0,0 +1,111 @@
+package game
+
+import "errors"
+
+// Move is a column choice: a normal drop, a pop of the player's own disc
+// from the bottom of the column (PopOut), or a power disc (Power Up)
+type Move struct {
+	Column int       `json:"column"`
+	Pop    bool      `json:"pop,omitempty"`
+	Power  PowerDisc `json:"power,omitempty"`
+}
+
+// repetitionLimit is how many times a PopOut position may occur before
+// the game is drawn
+const repetitionLimit = 3
+
+// PopDisc removes the bottom disc of column and moves the discs above it
+// down one row. It returns false if the column is empty.
+func PopDisc(board Board, column int) bool {
+	bottom := board.Rows() - 1
+	if board[bottom][column] == 0 {
+		return false
+	}
+	for row := bottom; row > 0; row-- {
+		board[row][column] = board[row-1][column]
+	}
+	board[0][column] = 0
+	return true
+}
+
+// MakePop pops player's own disc from the bottom of column in a PopOut game
+func MakePop(g *Game, column int, player int) string {
+	return PlayMove(g, Move{Column: column, Pop: true}, player)
+}
+
+// popOutVariant lets players pop their own bottom disc instead of
+// dropping one. A pop can complete lines for both players: the popper wins
+// if they have one, otherwise the opponent wins if they do. A position
+// occurring repetitionLimit times is a draw, and a full board is only a
+// draw once the next player can't pop either.
+type popOutVariant struct {
+	classicVariant
+}
+
+func (popOutVariant) Name() string { return "popout" }
+
+func (popOutVariant) Rules() Rules {
+	rules := ClassicRules
+	rules.PopOut = true
+	return rules
+}
+
+func (popOutVariant) Setup(g *Game) {
+	// the starting position counts towards repetition, pops can bring it back
+	g.PositionCounts = map[string]int{repetitionKey(g.Board, g.Turn): 1}
+}
+
+func (v popOutVariant) LegalMoves(g *Game, player int) []Move {
+	moves := v.classicVariant.LegalMoves(g, player)
+	for c := 0; c < g.Rules.Columns; c++ {
+		if g.Board[g.Rules.Rows-1][c] == player {
+			moves = append(moves, Move{Column: c, Pop: true})
+		}
+	}
+	return moves
+}
+
+func (v popOutVariant) ApplyMove(g *Game, m Move, player int) (bool, error) {
+	if !m.Pop {
+		return v.classicVariant.ApplyMove(g, m, player)
+	}
+	if g.Board[g.Rules.Rows-1][m.Column] != player {
+		return false, errors.New("You can only pop your own disc")
+	}
+	PopDisc(g.Board, m.Column)
+	return false, nil
+}
+
+func (popOutVariant) Result(g *Game, player, next int) (bool, int) {
+	if winner := lineWinner(g, player); winner != 0 {
+		return true, winner
+	}
+
+	if g.PositionCounts == nil {
+		g.PositionCounts = make(map[string]int)
+	}
+	key := repetitionKey(g.Board, next)
+	g.PositionCounts[key]++
+	if g.PositionCounts[key] >= repetitionLimit {
+		return true, 0
+	}
+
+	return CheckDraw(g.Board) && !canPopAny(g.Board, next), 0
+}
+
+// repetitionKey identifies board with player to move for counting
+// repetitions: its board string, which unlike Position.Key includes the
+// side to move
+func repetitionKey(board Board, player int) string {
+	return FormatBoard(board, player)
+}
+
+// canPopAny reports whether player has a disc on the bottom row
+func canPopAny(board Board, player int) bool {
+	for _, cell := range board[board.Rows()-1] {
+		if cell == player {
+			return true
+		}
+	}
+	return false
+}
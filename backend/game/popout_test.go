@@ -0,0 +1,190 @@
+package game
+
+import "testing"
+
+// newPopOutGame returns a PopOut game on the board of rows with player to
+// move
+func newPopOutGame(t *testing.T, rows [6]string, player int) *Game {
+	t.Helper()
+	popout, _ := LookupVariant("popout")
+	g := NewGameWithVariant("alice", "bob", popout, popout.Rules())
+	g.Board = boardFromRows(t, rows)
+	g.Turn = player
+	return g
+}
+
+func TestPopOwnDiscOnly(t *testing.T) {
+	rows := [6]string{".......", ".......", ".......", ".......", "XO.....", "OXX...."}
+	tests := []struct {
+		name   string
+		player int
+		column int
+		want   string
+	}{
+		{"opponent's disc", 1, 0, "You can only pop your own disc"},
+		{"empty column", 1, 3, "You can only pop your own disc"},
+		{"own disc", 1, 1, "OK"},
+		{"own disc with nothing above", 1, 2, "OK"},
+		{"player 2's own disc", 2, 0, "OK"},
+		{"player 2 popping player 1", 2, 2, "You can only pop your own disc"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newPopOutGame(t, rows, tc.player)
+			p := GamePosition(g)
+			if got := p.CanPop(tc.column); got != (tc.want == "OK") {
+				t.Errorf("Position.CanPop = %v", got)
+			}
+
+			before := g.Board.Clone()
+			if got := MakePop(g, tc.column, tc.player); got != tc.want {
+				t.Fatalf("MakePop = %q, want %q", got, tc.want)
+			}
+			if tc.want != "OK" {
+				if FormatBoard(g.Board, 1) != FormatBoard(before, 1) || g.Turn != tc.player || len(g.History) != 0 {
+					t.Error("rejected pop changed the game")
+				}
+				return
+			}
+
+			// The column drops by one, like Position.Pop
+			for row := 5; row > 0; row-- {
+				if g.Board[row][tc.column] != before[row-1][tc.column] {
+					t.Errorf("row %d of column %d is %d, want %d", row, tc.column, g.Board[row][tc.column], before[row-1][tc.column])
+				}
+			}
+			p.Pop(tc.column)
+			if got, want := p.String(), FormatBoard(g.Board, g.Turn); got != want {
+				t.Errorf("Position.Pop gives %s, the game %s", got, want)
+			}
+		})
+	}
+
+	// Classic games have no pops
+	g := NewGame("alice", "bob")
+	MakeMove(g, 0, 1)
+	MakeMove(g, 1, 2)
+	if got := MakePop(g, 0, 1); got == "OK" {
+		t.Error("popped in a classic game")
+	}
+}
+
+func TestPopCompletesLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		rows   [6]string
+		player int
+		column int
+		winner int
+		lines  int // winning lines reported, all the winner's
+	}{
+		{
+			// The pop lowers O into the bottom row and X into the next
+			name:   "both lines, player 1 pops",
+			rows:   [6]string{".......", ".......", ".......", "...X...", "...OXXX", "OOOXOXO"},
+			player: 1,
+			column: 3,
+			winner: 1,
+			lines:  1,
+		},
+		{
+			name:   "both lines, player 2 pops",
+			rows:   [6]string{".......", ".......", ".......", "...O...", "X..XOOO", "XXXOXOX"},
+			player: 2,
+			column: 3,
+			winner: 2,
+			lines:  1,
+		},
+		{
+			name:   "only the opponent's line",
+			rows:   [6]string{".......", ".......", ".......", ".......", "XXXO...", "OOOX..."},
+			player: 1,
+			column: 3,
+			winner: 2,
+			lines:  1,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newPopOutGame(t, tc.rows, tc.player)
+			p := GamePosition(g)
+			if p.HasWon(1) || p.HasWon(2) {
+				t.Fatal("someone has already won")
+			}
+
+			if got := MakePop(g, tc.column, tc.player); got != "WIN" {
+				t.Fatalf("MakePop = %q, want WIN", got)
+			}
+			if g.Winner != tc.winner || g.EndReason != ReasonConnect4 {
+				t.Errorf("winner %d by %s, want %d by connect4", g.Winner, g.EndReason, tc.winner)
+			}
+			if len(g.WinningLines) != tc.lines {
+				t.Errorf("%d winning lines, want %d", len(g.WinningLines), tc.lines)
+			}
+			for _, line := range g.WinningLines {
+				for _, c := range line {
+					if g.Board[c.Row][c.Column] != tc.winner {
+						t.Errorf("winning line %v isn't the winner's", line)
+						break
+					}
+				}
+			}
+
+			// The search scores the pop by the same rule
+			score := searchMove(p, Move{Column: tc.column, Pop: true}, 1, -WinScore-2, WinScore+2, nil)
+			if won := score > WinScore; won != (tc.winner == tc.player) {
+				t.Errorf("search scores the pop %d", score)
+			}
+		})
+	}
+}
+
+func TestPopOutRepetitionDraw(t *testing.T) {
+	popout, _ := LookupVariant("popout")
+	g := NewGameWithVariant("alice", "bob", popout, popout.Rules())
+
+	// Each cycle brings back the empty board with player 1 to move
+	cycle := []Move{{Column: 0}, {Column: 1}, {Column: 0, Pop: true}, {Column: 1, Pop: true}}
+	for i := 0; i < 2*len(cycle); i++ {
+		want := "OK"
+		if i == 2*len(cycle)-1 {
+			want = "DRAW" // the empty board for the third time
+		}
+		m := cycle[i%len(cycle)]
+		if got := PlayMove(g, m, g.Turn); got != want {
+			t.Fatalf("move %d %+v: %q, want %q", i+1, m, got, want)
+		}
+	}
+	if g.Winner != 0 || g.EndReason != ReasonRepetition {
+		t.Errorf("winner %d by %s, want a draw by repetition", g.Winner, g.EndReason)
+	}
+	if n := g.PositionCounts[repetitionKey(NewBoard(popout.Rules()), 1)]; n != repetitionLimit {
+		t.Errorf("empty board counted %d times, want %d", n, repetitionLimit)
+	}
+}
+
+func TestRepetitionKeyIncludesTurn(t *testing.T) {
+	// Position.Key can't tell these pairs apart
+	empty := NewBoard(ClassicRules)
+	board, _, _ := ParseBoard("7/7/7/7/7/xo5 x")
+	swapped, _, _ := ParseBoard("7/7/7/7/7/ox5 o")
+	pairs := []struct {
+		name   string
+		a, b   Board
+		ta, tb int
+	}{
+		{"empty board", empty, empty, 1, 2},
+		{"colours swapped", board, swapped, 1, 2},
+	}
+	for _, tc := range pairs {
+		if PositionFromBoard(ClassicRules, tc.a, tc.ta).Key() != PositionFromBoard(ClassicRules, tc.b, tc.tb).Key() {
+			t.Errorf("%s: Key tells them apart after all", tc.name)
+		}
+		if repetitionKey(tc.a, tc.ta) == repetitionKey(tc.b, tc.tb) {
+			t.Errorf("%s: same repetition key %q", tc.name, repetitionKey(tc.a, tc.ta))
+		}
+	}
+	if repetitionKey(board, 1) != repetitionKey(board.Clone(), 1) {
+		t.Error("equal positions have different repetition keys")
+	}
+}
@@ -0,0 +1,120 @@
+package game
+
+import "errors"
+
+// PowerDisc is a one-off special disc in the Power Up variant
+type PowerDisc string
+
+const (
+	PowerAnvil  PowerDisc = "anvil"  // clears the column and lands at the bottom
+	PowerBomb   PowerDisc = "bomb"   // destroys the top disc of the column, then itself
+	PowerDouble PowerDisc = "double" // a normal disc, then the same player moves again
+)
+
+// powerDiscs is the set of power discs each player starts with
+var powerDiscs = []PowerDisc{PowerAnvil, PowerBomb, PowerDouble}
+
+// powerUpVariant is classic Connect Four where each player may also use
+// each power disc once. Removing discs never completes a line, so only the
+// mover can win, and a full board is only a draw once the next player has
+// no anvil or bomb left to clear room with.
+type powerUpVariant struct {
+	classicVariant
+}
+
+func (powerUpVariant) Name() string { return "powerup" }
+
+func (powerUpVariant) Rules() Rules { return ClassicRules }
+
+func (powerUpVariant) Setup(g *Game) {
+	g.Powers = map[int][]PowerDisc{
+		1: append([]PowerDisc(nil), powerDiscs...),
+		2: append([]PowerDisc(nil), powerDiscs...),
+	}
+}
+
+func (v powerUpVariant) LegalMoves(g *Game, player int) []Move {
+	moves := v.classicVariant.LegalMoves(g, player)
+	for _, power := range g.Powers[player] {
+		for c := 0; c < g.Rules.Columns; c++ {
+			if powerFits(g.Board, c, power) {
+				moves = append(moves, Move{Column: c, Power: power})
+			}
+		}
+	}
+	return moves
+}
+
+func (v powerUpVariant) ApplyMove(g *Game, m Move, player int) (bool, error) {
+	if m.Power == "" {
+		return v.classicVariant.ApplyMove(g, m, player)
+	}
+	if m.Pop {
+		return false, errors.New("Popping is only allowed in PopOut")
+	}
+
+	left := g.Powers[player]
+	i := indexOfPower(left, m.Power)
+	if i == -1 {
+		if indexOfPower(powerDiscs, m.Power) == -1 {
+			return false, errors.New("Unknown power disc")
+		}
+		return false, errors.New("No " + string(m.Power) + " disc left")
+	}
+	if !powerFits(g.Board, m.Column, m.Power) {
+		return false, errors.New("Can't play " + string(m.Power) + " there")
+	}
+
+	switch m.Power {
+	case PowerAnvil:
+		for row := range g.Board {
+			g.Board[row][m.Column] = 0
+		}
+		g.Board[g.Rules.Rows-1][m.Column] = player
+	case PowerBomb:
+		for row := range g.Board {
+			if g.Board[row][m.Column] != 0 {
+				g.Board[row][m.Column] = 0
+				break
+			}
+		}
+	case PowerDouble:
+		DropDisc(g.Board, m.Column, player)
+	}
+
+	g.Powers[player] = append(left[:i:i], left[i+1:]...)
+	return m.Power == PowerDouble, nil
+}
+
+func (powerUpVariant) Result(g *Game, player, next int) (bool, int) {
+	if winner := lineWinner(g, player); winner != 0 {
+		return true, winner
+	}
+	canClear := indexOfPower(g.Powers[next], PowerAnvil) != -1 ||
+		indexOfPower(g.Powers[next], PowerBomb) != -1
+	return CheckDraw(g.Board) && !canClear, 0
+}
+
+// powerFits reports whether power can be played in column: anvils go
+// anywhere, bombs need a disc to destroy, doubles need room
+func powerFits(board Board, column int, power PowerDisc) bool {
+	switch power {
+	case PowerAnvil:
+		return true
+	case PowerBomb:
+		return board[board.Rows()-1][column] != 0
+	case PowerDouble:
+		return board[0][column] == 0
+	}
+	return false
+}
+
+// indexOfPower finds power in discs, or returns -1
+func indexOfPower(discs []PowerDisc, power PowerDisc) int {
+	for i, d := range discs {
+		if d == power {
+			return i
+		}
+	}
+	return -1
+}
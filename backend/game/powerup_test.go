@@ -0,0 +1,163 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newPowerUpGame returns a Power Up game on the board of rows with player
+// to move and every power disc still in hand
+func newPowerUpGame(t *testing.T, rows [6]string, player int) *Game {
+	t.Helper()
+	powerup, _ := LookupVariant("powerup")
+	g := NewGameWithVariant("alice", "bob", powerup, powerup.Rules())
+	g.Board = boardFromRows(t, rows)
+	g.Turn = player
+	return g
+}
+
+func TestPowerDiscs(t *testing.T) {
+	rows := [6]string{".......", ".......", ".......", "...O...", "...X...", "O..XX.."}
+	tests := []struct {
+		name  string
+		move  Move
+		want  string
+		after [6]string // the board afterwards, if the move is played
+		turn  int       // the player to move afterwards
+	}{
+		{
+			name:  "anvil clears the column",
+			move:  Move{Column: 3, Power: PowerAnvil},
+			want:  "OK",
+			after: [6]string{".......", ".......", ".......", ".......", ".......", "O..XX.."},
+			turn:  2,
+		},
+		{
+			name:  "anvil in an empty column",
+			move:  Move{Column: 6, Power: PowerAnvil},
+			want:  "OK",
+			after: [6]string{".......", ".......", ".......", "...O...", "...X...", "O..XX.X"},
+			turn:  2,
+		},
+		{
+			name:  "bomb removes only the top disc",
+			move:  Move{Column: 3, Power: PowerBomb},
+			want:  "OK",
+			after: [6]string{".......", ".......", ".......", ".......", "...X...", "O..XX.."},
+			turn:  2,
+		},
+		{
+			name:  "bomb on the opponent's only disc",
+			move:  Move{Column: 0, Power: PowerBomb},
+			want:  "OK",
+			after: [6]string{".......", ".......", ".......", "...O...", "...X...", "...XX.."},
+			turn:  2,
+		},
+		{
+			name:  "double moves again",
+			move:  Move{Column: 4, Power: PowerDouble},
+			want:  "OK",
+			after: [6]string{".......", ".......", ".......", "...O...", "...XX..", "O..XX.."},
+			turn:  1,
+		},
+		{
+			name: "bomb in an empty column",
+			move: Move{Column: 6, Power: PowerBomb},
+			want: "Can't play bomb there",
+		},
+		{
+			name: "unknown power",
+			move: Move{Column: 3, Power: "laser"},
+			want: "Unknown power disc",
+		},
+		{
+			name: "power disc popped",
+			move: Move{Column: 3, Power: PowerBomb, Pop: true},
+			want: "Popping is only allowed in PopOut",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newPowerUpGame(t, rows, 1)
+			if got := PlayMove(g, tc.move, 1); got != tc.want {
+				t.Fatalf("PlayMove = %q, want %q", got, tc.want)
+			}
+			if tc.want != "OK" {
+				if !reflect.DeepEqual(g.Board, boardFromRows(t, rows)) || g.Turn != 1 || len(g.Powers[1]) != len(powerDiscs) {
+					t.Error("rejected move changed the game")
+				}
+				return
+			}
+			if want := boardFromRows(t, tc.after); !reflect.DeepEqual(g.Board, want) {
+				t.Errorf("board %s, want %s", FormatBoard(g.Board, g.Turn), FormatBoard(want, tc.turn))
+			}
+			if g.Turn != tc.turn {
+				t.Errorf("player %d to move, want %d", g.Turn, tc.turn)
+			}
+			if indexOfPower(g.Powers[1], tc.move.Power) != -1 || len(g.Powers[1]) != len(powerDiscs)-1 {
+				t.Errorf("player 1 has %v left", g.Powers[1])
+			}
+			if len(g.Powers[2]) != len(powerDiscs) {
+				t.Errorf("player 2 has %v left", g.Powers[2])
+			}
+
+			// Each power disc is used once
+			g.Turn = 1
+			if got := PlayMove(g, Move{Column: 3, Power: tc.move.Power}, 1); got != "No "+string(tc.move.Power)+" disc left" {
+				t.Errorf("second %s: %q", tc.move.Power, got)
+			}
+		})
+	}
+}
+
+func TestPowerUpDoubleWins(t *testing.T) {
+	// The double's disc completes the line: the game ends without the
+	// extra move
+	g := newPowerUpGame(t, [6]string{".......", ".......", ".......", ".......", "OOO....", "XXX...."}, 1)
+	if got := PlayMove(g, Move{Column: 3, Power: PowerDouble}, 1); got != "WIN" {
+		t.Fatalf("PlayMove = %q, want WIN", got)
+	}
+	if g.Winner != 1 || g.EndReason != ReasonConnect4 {
+		t.Errorf("winner %d by %s", g.Winner, g.EndReason)
+	}
+}
+
+func TestPowerUpFullBoard(t *testing.T) {
+	// Player 1 fills the board without a line; it is only a draw once
+	// player 2 has nothing to clear room with
+	rows := [6]string{"XOXOXO.", "XOXOXOX", "OXOXOXO", "OXOXOXO", "XOXOXOX", "XOXOXOX"}
+	tests := []struct {
+		name   string
+		powers []PowerDisc // player 2's
+		want   string
+	}{
+		{"anvil and bomb left", []PowerDisc{PowerAnvil, PowerBomb, PowerDouble}, "OK"},
+		{"anvil left", []PowerDisc{PowerAnvil}, "OK"},
+		{"bomb left", []PowerDisc{PowerBomb}, "OK"},
+		{"only a double left", []PowerDisc{PowerDouble}, "DRAW"},
+		{"nothing left", nil, "DRAW"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newPowerUpGame(t, rows, 1)
+			g.Powers[2] = tc.powers
+			if got := MakeMove(g, 6, 1); got != tc.want {
+				t.Fatalf("MakeMove = %q, want %q", got, tc.want)
+			}
+			if tc.want == "DRAW" {
+				if g.EndReason != ReasonBoardFull {
+					t.Errorf("draw by %s, want board_full", g.EndReason)
+				}
+				return
+			}
+			// Player 2 clears room, and the game goes on
+			moves := VariantOf(g).LegalMoves(g, 2)
+			if len(moves) == 0 {
+				t.Fatal("no legal move on the full board")
+			}
+			if got := PlayMove(g, moves[0], 2); got != "OK" {
+				t.Errorf("%+v on the full board: %q", moves[0], got)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package game
+
+import "time"
+
+// MatchQueue holds the players waiting for an opponent, oldest first. It
+// does no locking of its own: the matchmaker only uses it with matchLock
+// held, alongside the active games it pairs players into.
+type MatchQueue struct {
+	waiting []queueEntry
+}
+
+// queueEntry is one waiting player and the game they asked for
+type queueEntry struct {
+	username string
+	rating   float64
+	opts     MatchOptions
+	joined   time.Time
+	bot      *BotOptions // Bot to start once the rating window times out, nil for none
+}
+
+// RatingWindow is how far apart two players' ratings may be for them to be
+// paired. It starts at Initial and widens by PerSecond for every second a
+// player has waited, up to Max. A player still unmatched after Timeout gets
+// the bot instead.
+type RatingWindow struct {
+	Initial   float64
+	PerSecond float64
+	Max       float64
+	Timeout   time.Duration
+}
+
+// Width is the window of a player who has waited for waited
+func (w RatingWindow) Width(waited time.Duration) float64 {
+	width := w.Initial + w.PerSecond*waited.Seconds()
+	if width > w.Max {
+		return w.Max
+	}
+	return width
+}
+
+// accepts reports whether a player who has waited for waited accepts an
+// opponent rated diff points away
+func (w RatingWindow) accepts(diff float64, waited time.Duration) bool {
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= w.Width(waited)
+}
+
+// join adds e to the back of the queue. A player who is already waiting is
+// moved to the back with their new options.
+func (q *MatchQueue) join(e queueEntry) {
+	q.remove(e.username)
+	q.waiting = append(q.waiting, e)
+}
+
+// match removes and returns the longest-waiting player other than e who
+// asked for the same game and whose rating fits both players' windows as
+// of now. ok is false if there is none.
+func (q *MatchQueue) match(e queueEntry, w RatingWindow, now time.Time) (opponent queueEntry, ok bool) {
+	for i, o := range q.waiting {
+		if o.username == e.username || o.opts != e.opts {
+			continue
+		}
+		diff := o.rating - e.rating
+		if w.accepts(diff, now.Sub(o.joined)) && w.accepts(diff, now.Sub(e.joined)) {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			return o, true
+		}
+	}
+	return queueEntry{}, false
+}
+
+// find returns username's entry, or nil if they aren't waiting
+func (q *MatchQueue) find(username string) *queueEntry {
+	for i := range q.waiting {
+		if q.waiting[i].username == username {
+			return &q.waiting[i]
+		}
+	}
+	return nil
+}
+
+// remove takes username out of the queue and reports whether they were in it
+func (q *MatchQueue) remove(username string) bool {
+	for i, e := range q.waiting {
+		if e.username == username {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// position is username's place in the queue, 1 for the longest waiting,
+// or 0 if they aren't in it
+func (q *MatchQueue) position(username string) int {
+	for i, e := range q.waiting {
+		if e.username == username {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Len is the number of waiting players
+func (q *MatchQueue) Len() int {
+	return len(q.waiting)
+}
@@ -0,0 +1,302 @@
+package game
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// matchWith returns options that only match other players given the same n
+func matchWith(n int) MatchOptions {
+	return MatchOptions{Variant: DefaultVariant, Rules: ClassicRules, TimeControl: TimeControl{PerMove: time.Duration(n+1) * time.Second}}
+}
+
+func TestMatchQueueFIFO(t *testing.T) {
+	var q MatchQueue
+	now := time.Now()
+	entry := func(name string, opts MatchOptions) queueEntry {
+		return queueEntry{username: name, rating: DefaultRating, opts: opts, joined: now}
+	}
+	q.join(entry("a", matchWith(1)))
+	q.join(entry("b", matchWith(2)))
+	q.join(entry("c", matchWith(1)))
+	q.join(entry("d", matchWith(1)))
+	q.join(entry("a", matchWith(1))) // rejoining moves a to the back
+
+	for i, want := range []string{"b", "c", "d", "a"} {
+		if got := q.position(want); got != i+1 {
+			t.Errorf("position(%s) = %d, want %d", want, got, i+1)
+		}
+	}
+	for _, want := range []string{"c", "d", "a", ""} {
+		if got, _ := q.match(entry("e", matchWith(1)), matchWindow, now); got.username != want {
+			t.Errorf("match = %q, want %q", got.username, want)
+		}
+	}
+	if _, ok := q.match(entry("b", matchWith(2)), matchWindow, now); ok {
+		t.Errorf("match paired b with themselves")
+	}
+	if !q.remove("b") || q.remove("b") || q.Len() != 0 {
+		t.Errorf("remove left %d waiting", q.Len())
+	}
+}
+
+func TestRatingWindowWidth(t *testing.T) {
+	for _, tc := range []struct {
+		waited time.Duration
+		want   float64
+	}{
+		{0, 100},
+		{5 * time.Second, 300},
+		{10 * time.Second, 500},
+		{time.Minute, 500},
+	} {
+		if got := matchWindow.Width(tc.waited); got != tc.want {
+			t.Errorf("Width(%s) = %v, want %v", tc.waited, got, tc.want)
+		}
+	}
+}
+
+func TestFindMatchByRating(t *testing.T) {
+	resetMatchmaker(t)
+
+	FindMatch("a", 1500, classicMatch)
+	if g := FindMatch("b", 1800, classicMatch); g != nil {
+		t.Fatalf("paired players 300 points apart straight away")
+	}
+	// c is within a's window, and a has waited longest
+	if g := FindMatch("c", 1450, classicMatch); g == nil || g.Player1 != "a" || g.Player2 != "c" {
+		t.Fatalf("FindMatch(c) = %+v, want a vs c", g)
+	}
+
+	// b and d are 300 apart: too far after 3 seconds, close enough after 6
+	FindMatch("d", 2100, classicMatch)
+	sweepQueue(time.Now().Add(3 * time.Second))
+	if FindGameByUsername("b") != nil {
+		t.Fatal("paired b and d before their windows were wide enough")
+	}
+	sweepQueue(time.Now().Add(6 * time.Second))
+	if g := FindGameByUsername("b"); g == nil || g.Player1 != "b" || g.Player2 != "d" {
+		t.Fatalf("after 6s b is in %+v, want b vs d", g)
+	}
+}
+
+func TestBotMatchesRating(t *testing.T) {
+	resetMatchmaker(t)
+
+	tests := []struct {
+		rating float64
+		bot    BotOptions
+		want   Difficulty
+	}{
+		{700, BotOptions{}, DifficultyBeginner},
+		{1250, BotOptions{}, DifficultyCasual},
+		{1500, BotOptions{}, DifficultyStrong},
+		{1850, BotOptions{}, DifficultyMCTS},
+		{2250, BotOptions{}, DifficultyExpert},
+		{2250, BotOptions{Difficulty: DifficultyBeginner, BotName: DifficultyBeginner.BotName()}, DifficultyBeginner},
+	}
+	for i, tc := range tests {
+		name := fmt.Sprintf("player%d", i)
+		// Different games, so they get bots rather than each other
+		FindMatch(name, tc.rating, matchWith(i))
+		StartBotIfNoPlayer(name, tc.bot)
+	}
+
+	sweepQueue(time.Now().Add(matchWindow.Timeout - time.Second))
+	if FindGameByUsername("player0") != nil {
+		t.Fatal("bot joined before the window timed out")
+	}
+	sweepQueue(time.Now().Add(matchWindow.Timeout))
+	for i, tc := range tests {
+		g := FindGameByUsername(fmt.Sprintf("player%d", i))
+		if g == nil {
+			t.Errorf("rating %v: no bot game", tc.rating)
+			continue
+		}
+		if g.Difficulty != tc.want || g.BotName != tc.want.BotName() {
+			t.Errorf("rating %v: got %s (%s), want %s", tc.rating, g.Difficulty, g.BotName, tc.want)
+		}
+	}
+}
+
+func TestParseDifficulty(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Difficulty
+		ok   bool
+	}{
+		{"", DefaultDifficulty, true},
+		{"beginner", DifficultyBeginner, true},
+		{"expert", DifficultyExpert, true},
+		{"perfect", DifficultyExpert, true}, // the old name
+		{"mcts", DifficultyMCTS, true},
+		{"Expert", "", false},
+		{"godlike", "", false},
+	}
+	for _, tc := range tests {
+		if got, ok := ParseDifficulty(tc.s); got != tc.want || ok != tc.ok {
+			t.Errorf("ParseDifficulty(%q) = %q, %v, want %q, %v", tc.s, got, ok, tc.want, tc.ok)
+		}
+	}
+	if _, ok := LookupBot(DifficultyExpert.BotName()); !ok {
+		t.Error("no bot plays the expert level")
+	}
+}
+
+func TestFindMatchQueuesEveryone(t *testing.T) {
+	resetMatchmaker(t)
+
+	// Nobody wants the same game, so all three wait in arrival order
+	for i, name := range []string{"a", "b", "c"} {
+		if g := FindMatch(name, DefaultRating, matchWith(i)); g != nil {
+			t.Fatalf("%s matched with nobody to match", name)
+		}
+		if pos, n := QueuePosition(name); pos != i+1 || n != i+1 {
+			t.Errorf("QueuePosition(%s) = %d of %d, want %d of %d", name, pos, n, i+1, i+1)
+		}
+	}
+
+	// d wants b's game: b leaves the queue and c moves up
+	g := FindMatch("d", DefaultRating, matchWith(1))
+	if g == nil || g.Player1 != "b" || g.Player2 != "d" {
+		t.Fatalf("FindMatch(d) = %+v, want b vs d", g)
+	}
+	if pos, n := QueuePosition("c"); pos != 2 || n != 2 {
+		t.Errorf("QueuePosition(c) = %d of %d, want 2 of 2", pos, n)
+	}
+	if pos, _ := QueuePosition("b"); pos != 0 {
+		t.Errorf("b still queued at %d after being matched", pos)
+	}
+
+	// a leaves: c is next in line, and a can't be matched any more
+	if !CancelMatch("a") || CancelMatch("a") {
+		t.Error("CancelMatch(a) should succeed exactly once")
+	}
+	if pos, n := QueuePosition("c"); pos != 1 || n != 1 {
+		t.Errorf("QueuePosition(c) = %d of %d, want 1 of 1", pos, n)
+	}
+	if g := FindMatch("e", DefaultRating, matchWith(0)); g != nil {
+		t.Errorf("e matched with a, who left the queue")
+	}
+}
+
+func TestFindMatchRequeue(t *testing.T) {
+	resetMatchmaker(t)
+
+	FindMatch("a", DefaultRating, matchWith(0))
+	FindMatch("b", DefaultRating, matchWith(1))
+	// a connects again (say from another tab) asking for b's game
+	g := FindMatch("a", DefaultRating, matchWith(1))
+	if g == nil || g.Player1 != "b" || g.Player2 != "a" {
+		t.Fatalf("FindMatch(a) = %+v, want b vs a", g)
+	}
+	if _, n := QueuePosition(""); n != 0 {
+		t.Errorf("%d still waiting, want a's old place gone", n)
+	}
+}
+
+func TestFindMatchMixedOptionsConcurrent(t *testing.T) {
+	resetMatchmaker(t)
+
+	const players = 300
+	var wg sync.WaitGroup
+	for i := 0; i < players; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			FindMatch(fmt.Sprintf("player%d", i), DefaultRating, matchWith(i%7))
+		}(i)
+	}
+	wg.Wait()
+
+	// Everyone is either in a game or waiting, never both, and nobody
+	// waits while someone wanting the same game waits too
+	waitingFor := make(map[MatchOptions]string)
+	for i := 0; i < players; i++ {
+		name := fmt.Sprintf("player%d", i)
+		pos, _ := QueuePosition(name)
+		inGame := FindGameByUsername(name) != nil
+		if (pos != 0) == inGame {
+			t.Errorf("%s: queued at %d, in a game %v", name, pos, inGame)
+		}
+		if pos != 0 {
+			if other, ok := waitingFor[matchWith(i%7)]; ok {
+				t.Errorf("%s and %s both waiting for the same game", other, name)
+			}
+			waitingFor[matchWith(i%7)] = name
+		}
+	}
+}
+
+func TestCancelMatchRacesFindMatch(t *testing.T) {
+	resetMatchmaker(t)
+
+	for i := 0; i < 200; i++ {
+		a, b := fmt.Sprintf("a%d", i), fmt.Sprintf("b%d", i)
+		FindMatch(a, DefaultRating, classicMatch)
+
+		var cancelled bool
+		var g *Game
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			cancelled = CancelMatch(a)
+		}()
+		go func() {
+			defer wg.Done()
+			g = FindMatch(b, DefaultRating, classicMatch)
+		}()
+		wg.Wait()
+
+		// Either a left first and b waits, or a was matched first and
+		// can't cancel
+		if cancelled == (g != nil) {
+			t.Fatalf("round %d: cancelled %v, game %v", i, cancelled, g != nil)
+		}
+		if cancelled && FindGameByUsername(a) != nil {
+			t.Fatalf("round %d: %s got a game after leaving the queue", i, a)
+		}
+		// Empty the queue for the next round
+		CancelMatch(b)
+	}
+}
+
+func TestCancelMatchRacesBot(t *testing.T) {
+	resetMatchmaker(t)
+
+	// Each player leaves just as the queue is swept as if their window had
+	// long timed out: they either leave or get the bot, never both
+	later := time.Now().Add(time.Hour)
+	const players = 100
+	cancelled := make([]bool, players)
+	var wg sync.WaitGroup
+	for i := 0; i < players; i++ {
+		name := fmt.Sprintf("player%d", i)
+		FindMatch(name, DefaultRating, matchWith(i))
+		StartBotIfNoPlayer(name, BotOptions{Difficulty: DifficultyBeginner, BotName: DifficultyBeginner.BotName()})
+
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+			cancelled[i] = CancelMatch(name)
+		}(i)
+		go func() {
+			defer wg.Done()
+			sweepQueue(later)
+		}()
+	}
+	wg.Wait()
+	sweepQueue(later)
+
+	for i := 0; i < players; i++ {
+		name := fmt.Sprintf("player%d", i)
+		if inGame := FindGameByUsername(name) != nil; inGame == cancelled[i] {
+			t.Errorf("%s: cancelled %v, bot game %v", name, cancelled[i], inGame)
+		}
+	}
+}
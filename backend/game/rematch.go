@@ -0,0 +1,170 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Series is the running score between two players over a game and its
+// rematches
+type Series struct {
+	Games int            `json:"games"`
+	Wins  map[string]int `json:"wins"` // by username
+	Draws int            `json:"draws"`
+}
+
+// SeriesScore returns the score of g's series, counting g once it is over
+func SeriesScore(g *Game) Series {
+	s := Series{Games: g.Series.Games, Draws: g.Series.Draws, Wins: make(map[string]int)}
+	for name, wins := range g.Series.Wins {
+		s.Wins[name] = wins
+	}
+	if g.GameOver {
+		s.Games++
+		switch g.Winner {
+		case 0:
+			s.Draws++
+		case 1:
+			s.Wins[g.Player1]++
+		case 2:
+			s.Wins[g.Player2]++
+		}
+	}
+	return s
+}
+
+// RequestRematch records that player wants to play again once g is over.
+// The opponent answers with AcceptRematch or DeclineRematch.
+func RequestRematch(g *Game, player int) error {
+	if err := canRematch(g); err != nil {
+		return err
+	}
+	if g.RematchRequest != 0 {
+		return errors.New("Rematch already requested")
+	}
+	g.RematchRequest = player
+	return nil
+}
+
+// AcceptRematch grants the opponent's pending request and returns the new
+// game. Both players must still be connected to g and not have moved on to
+// another game or the queue, or the rematch would take them out of it.
+func AcceptRematch(g *Game, player int) (*Game, error) {
+	if err := canRematch(g); err != nil {
+		return nil, err
+	}
+	if g.RematchRequest != opponent(player) {
+		return nil, errors.New("No rematch to accept")
+	}
+	for _, name := range []string{g.Player1, g.Player2} {
+		if !g.Connections[name] {
+			g.RematchRequest = 0
+			return nil, fmt.Errorf("%s has left", name)
+		}
+	}
+
+	matchLock.Lock()
+	defer matchLock.Unlock()
+	for _, name := range []string{g.Player1, g.Player2} {
+		if busyElsewhere(g, name) {
+			g.RematchRequest = 0
+			return nil, fmt.Errorf("%s is already playing another game", name)
+		}
+	}
+	return rematch(g), nil
+}
+
+// DeclineRematch refuses the opponent's pending request
+func DeclineRematch(g *Game, player int) error {
+	if g.RematchRequest != opponent(player) {
+		return errors.New("No rematch to decline")
+	}
+	g.RematchRequest = 0
+	return nil
+}
+
+// canRematch checks that g is over, its result announced, and that it
+// hasn't been rematched yet
+func canRematch(g *Game) error {
+	if !g.GameOver {
+		return errors.New("Game is still in progress")
+	}
+	if g.next != nil {
+		return errors.New("Rematch already started")
+	}
+	if g.Saving {
+		return errors.New("Still saving the result, try again in a moment")
+	}
+	return nil
+}
+
+// busyElsewhere reports whether username is queued, hosting a room or in a
+// game other than g. matchLock must be held.
+func busyElsewhere(g *Game, username string) bool {
+	if other := activeGames[username]; other != nil && other != g {
+		return true
+	}
+	if queue.find(username) != nil {
+		return true
+	}
+	for _, r := range rooms {
+		if r.host == username && time.Since(r.created) < RoomTTL {
+			return true
+		}
+	}
+	return false
+}
+
+// Rematch starts a new game between g's players with the same options and
+// colours swapped, carrying the series score over, and registers it as
+// both players' active game. Bot games keep the same bot.
+func Rematch(g *Game) *Game {
+	matchLock.Lock()
+	defer matchLock.Unlock()
+	return rematch(g)
+}
+
+// rematch is Rematch with matchLock held
+func rematch(g *Game) *Game {
+	next := matchOptionsOf(g).newGame(g.Player2, g.Player1)
+	next.Series = SeriesScore(g)
+	next.Difficulty = g.Difficulty
+	next.BotName = g.BotName
+	next.Practice = g.Practice
+	if g.BotPlayer != 0 {
+		next.BotPlayer = opponent(g.BotPlayer)
+	}
+	for name, connected := range g.Connections {
+		next.Connections[name] = connected
+	}
+	for name, seen := range g.LastSeen {
+		next.LastSeen[name] = seen
+	}
+	g.RematchRequest = 0
+	g.next = next
+
+	for _, name := range []string{next.Player1, next.Player2} {
+		if name != BotPlayerName || next.BotName == "" {
+			activeGames[name] = next
+		}
+	}
+
+	log.Printf("Rematch %s of game %s: %s vs %s (game %d of the series)", next.ID, g.ID, next.Player1, next.Player2, next.Series.Games+1)
+	return next
+}
+
+// Latest follows g's rematches to the game its players are in now. It
+// takes each game's lock in turn, so the caller must hold none of them.
+func Latest(g *Game) *Game {
+	for {
+		g.Lock()
+		next := g.next
+		g.Unlock()
+		if next == nil {
+			return g
+		}
+		g = next
+	}
+}
@@ -0,0 +1,155 @@
+package game
+
+import (
+	"crypto/rand"
+	"errors"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// RoomTTL is how long a private room waits for the invited player
+const RoomTTL = 5 * time.Minute
+
+// roomCodeAlphabet leaves out letters and digits that are easily confused
+// (0/O, 1/I/L) since codes are read out and typed by hand
+const roomCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// roomCodeLength is the number of characters in an invite code
+const roomCodeLength = 6
+
+// room is a private game waiting for the host's friend. Rooms are kept in
+// rooms, guarded by matchLock like the queue.
+type room struct {
+	host    string
+	opts    MatchOptions
+	created time.Time
+}
+
+// rooms maps invite codes to open rooms
+var rooms = make(map[string]*room)
+
+// CreateRoom opens a private room for a game of opts hosted by host and
+// returns its invite code. The room closes after RoomTTL if nobody joins;
+// the bot never does. A host has one room at a time, and isn't queued for
+// a public game while it is open.
+func CreateRoom(host string, opts MatchOptions) string {
+	startMatching()
+
+	matchLock.Lock()
+	defer matchLock.Unlock()
+
+	queue.remove(host)
+	for code, r := range rooms {
+		if r.host == host {
+			delete(rooms, code)
+		}
+	}
+
+	code := newRoomCode()
+	for rooms[code] != nil {
+		code = newRoomCode()
+	}
+	rooms[code] = &room{host: host, opts: opts, created: time.Now()}
+	log.Printf("Room %s created by %s (%s %s)", code, host, opts.Variant, opts.Rules)
+	return code
+}
+
+// JoinRoom starts the game in room code between its host and username. It
+// fails if either of them is still playing another game.
+func JoinRoom(code, username string) (*Game, error) {
+	code = strings.ToUpper(code)
+
+	// Look at the players' current games without matchLock, since a game's
+	// lock is never taken while holding it, then check nothing moved on
+	matchLock.Lock()
+	r := rooms[code]
+	var current []*Game
+	if r != nil {
+		current = []*Game{activeGames[r.host], activeGames[username]}
+	}
+	matchLock.Unlock()
+	if r == nil || time.Since(r.created) >= RoomTTL {
+		return nil, errors.New("Room not found or expired")
+	}
+	if r.host == username {
+		return nil, errors.New("You can't join your own room")
+	}
+	for i, g := range current {
+		if g == nil {
+			continue
+		}
+		g.Lock()
+		over := g.GameOver
+		g.Unlock()
+		if !over {
+			if i == 0 {
+				return nil, errors.New("The host is already in a game")
+			}
+			return nil, errors.New("You are already in a game")
+		}
+	}
+
+	matchLock.Lock()
+	defer matchLock.Unlock()
+	if rooms[code] != r {
+		return nil, errors.New("Room not found or expired")
+	}
+	if activeGames[r.host] != current[0] || activeGames[username] != current[1] {
+		return nil, errors.New("A game has just started for one of you, try again")
+	}
+	delete(rooms, code)
+
+	queue.remove(username)
+	game := r.opts.newGame(r.host, username)
+	activeGames[game.Player1] = game
+	activeGames[game.Player2] = game
+	log.Printf("Room %s: %s joined %s (Game ID: %s)", code, username, r.host, game.ID)
+	return game, nil
+}
+
+// CloseRoom closes room code, e.g. when the host's socket closes. It
+// reports false if the room was already gone: joined or expired.
+func CloseRoom(code string) bool {
+	matchLock.Lock()
+	defer matchLock.Unlock()
+	if rooms[code] == nil {
+		return false
+	}
+	delete(rooms, code)
+	return true
+}
+
+// RoomOpen reports whether room code is still waiting for a player
+func RoomOpen(code string) bool {
+	matchLock.Lock()
+	defer matchLock.Unlock()
+	return rooms[code] != nil
+}
+
+// expireRooms closes rooms nobody joined within RoomTTL as of now
+func expireRooms(now time.Time) {
+	matchLock.Lock()
+	defer matchLock.Unlock()
+	for code, r := range rooms {
+		if now.Sub(r.created) >= RoomTTL {
+			delete(rooms, code)
+			log.Printf("Room %s expired (nobody joined %s)", code, r.host)
+		}
+	}
+}
+
+// newRoomCode returns a random invite code
+func newRoomCode() string {
+	code := make([]byte, roomCodeLength)
+	max := big.NewInt(int64(len(roomCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			panic(err) // crypto/rand doesn't fail on supported platforms
+		}
+		code[i] = roomCodeAlphabet[n.Int64()]
+	}
+	return string(code)
+}
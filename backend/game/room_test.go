@@ -0,0 +1,120 @@
+package game
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJoinRoom(t *testing.T) {
+	resetMatchmaker(t)
+
+	opts := MatchOptions{Variant: DefaultVariant, Rules: Rules{Rows: 7, Columns: 8, Connect: 4}}
+	code := CreateRoom("host", opts)
+	if len(code) != roomCodeLength || strings.Trim(code, roomCodeAlphabet) != "" {
+		t.Fatalf("bad code %q", code)
+	}
+	if _, err := JoinRoom(code, "host"); err == nil {
+		t.Error("host joined their own room")
+	}
+	if !RoomOpen(code) {
+		t.Fatal("room closed by the host's failed join")
+	}
+
+	g, err := JoinRoom(strings.ToLower(code), "guest")
+	if err != nil {
+		t.Fatalf("join with lower case code: %v", err)
+	}
+	if g.Player1 != "host" || g.Player2 != "guest" || g.BotPlayer != 0 || g.Rules != opts.Rules {
+		t.Errorf("got %s vs %s (bot %d) on %s", g.Player1, g.Player2, g.BotPlayer, g.Rules)
+	}
+	for _, name := range []string{"host", "guest"} {
+		if FindGameByUsername(name) != g {
+			t.Errorf("%s isn't registered in the game", name)
+		}
+	}
+	if RoomOpen(code) || CloseRoom(code) {
+		t.Error("room still open after the join")
+	}
+	if _, err := JoinRoom(code, "third"); err == nil {
+		t.Error("room joined twice")
+	}
+}
+
+func TestRoomExpires(t *testing.T) {
+	resetMatchmaker(t)
+
+	code := CreateRoom("host", classicMatch)
+	expireRooms(time.Now().Add(RoomTTL - time.Second))
+	if !RoomOpen(code) {
+		t.Fatal("room expired early")
+	}
+	expireRooms(time.Now().Add(RoomTTL))
+	if RoomOpen(code) {
+		t.Fatal("room open after RoomTTL")
+	}
+	if _, err := JoinRoom(code, "guest"); err == nil {
+		t.Error("joined an expired room")
+	}
+	if CloseRoom(code) {
+		t.Error("closed an expired room")
+	}
+}
+
+func TestJoinRoomWhilePlaying(t *testing.T) {
+	tests := []struct {
+		name   string
+		player string // who is already playing
+	}{
+		{"host playing", "host"},
+		{"guest playing", "guest"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resetMatchmaker(t)
+
+			code := CreateRoom("host", classicMatch)
+			FindMatch(tc.player, DefaultRating, classicMatch)
+			live := FindMatch("opponent", DefaultRating, classicMatch)
+			if live == nil {
+				t.Fatal("no game to be busy in")
+			}
+			if _, err := JoinRoom(code, "guest"); err == nil {
+				t.Fatal("joined while a player is in a live game")
+			}
+			if FindGameByUsername(tc.player) != live {
+				t.Error("live game replaced")
+			}
+
+			// Once that game is over the room can be joined
+			live.Lock()
+			Resign(live, 1)
+			live.Unlock()
+			code = CreateRoom("host", classicMatch)
+			if _, err := JoinRoom(code, "guest"); err != nil {
+				t.Errorf("join after the game ended: %v", err)
+			}
+		})
+	}
+}
+
+func TestRoomHostNeverGetsBot(t *testing.T) {
+	resetMatchmaker(t)
+
+	// The host was queued with a bot fallback before opening the room
+	FindMatch("host", DefaultRating, classicMatch)
+	StartBotIfNoPlayer("host", BotOptions{})
+	code := CreateRoom("host", classicMatch)
+	StartBotIfNoPlayer("host", BotOptions{})
+
+	sweepQueue(time.Now().Add(matchWindow.Timeout))
+	if g := FindGameByUsername("host"); g != nil {
+		t.Fatalf("host got game %s against %s", g.ID, g.Player2)
+	}
+	if pos, _ := QueuePosition("host"); pos != 0 {
+		t.Errorf("host queued at %d while hosting", pos)
+	}
+	if !RoomOpen(code) {
+		t.Error("room closed by the sweep")
+	}
+}
@@ -0,0 +1,80 @@
+package game
+
+import "fmt"
+
+// Rules sets the board size, how many discs in a row win and whether
+// players may pop their own discs from the bottom row (the PopOut variant)
+type Rules struct {
+	Rows    int  `json:"rows"`
+	Columns int  `json:"columns"`
+	Connect int  `json:"connect"`
+	PopOut  bool `json:"popOut"`
+}
+
+// ClassicRules is standard Connect Four: 6 rows, 7 columns, four in a row
+var ClassicRules = Rules{Rows: 6, Columns: 7, Connect: 4}
+
+// Limits on Rules. Every column plus a sentinel cell must fit in the
+// 64-bit bitboard, so (Rows+1)*Columns can't exceed 64.
+const (
+	minBoardSide = 4
+	maxColumns   = 12
+	minConnect   = 3
+)
+
+// Validate checks that the rules describe a playable board
+func (r Rules) Validate() error {
+	if r.Rows < minBoardSide || r.Columns < minBoardSide {
+		return fmt.Errorf("board must be at least %dx%d", minBoardSide, minBoardSide)
+	}
+	if r.Columns > maxColumns || (r.Rows+1)*r.Columns > 64 {
+		return fmt.Errorf("board %dx%d is too large", r.Rows, r.Columns)
+	}
+	if r.Connect < minConnect || (r.Connect > r.Rows && r.Connect > r.Columns) {
+		return fmt.Errorf("connect %d doesn't fit a %dx%d board", r.Connect, r.Rows, r.Columns)
+	}
+	return nil
+}
+
+// String formats the rules like "6x7 connect 4", adding "popout" for PopOut
+func (r Rules) String() string {
+	s := fmt.Sprintf("%dx%d connect %d", r.Rows, r.Columns, r.Connect)
+	if r.PopOut {
+		s += " popout"
+	}
+	return s
+}
+
+// Board holds the discs of a game, row 0 at the top: 0 empty, 1 or 2 for a player
+type Board [][]int
+
+// NewBoard returns an empty board sized for rules
+func NewBoard(rules Rules) Board {
+	board := make(Board, rules.Rows)
+	for row := range board {
+		board[row] = make([]int, rules.Columns)
+	}
+	return board
+}
+
+// Clone returns a deep copy of the board
+func (b Board) Clone() Board {
+	c := make(Board, len(b))
+	for row := range b {
+		c[row] = append([]int(nil), b[row]...)
+	}
+	return c
+}
+
+// Rows returns the number of rows
+func (b Board) Rows() int {
+	return len(b)
+}
+
+// Columns returns the number of columns
+func (b Board) Columns() int {
+	if len(b) == 0 {
+		return 0
+	}
+	return len(b[0])
+}
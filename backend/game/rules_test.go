@@ -0,0 +1,156 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	rules7x8 = Rules{Rows: 7, Columns: 8, Connect: 4}
+	rules6x9 = Rules{Rows: 6, Columns: 9, Connect: 5}
+)
+
+func TestRulesValidate(t *testing.T) {
+	tests := []struct {
+		rules Rules
+		ok    bool
+	}{
+		{ClassicRules, true},
+		{rules7x8, true},
+		{rules6x9, true},
+		{Rules{Rows: 4, Columns: 4, Connect: 3}, true},
+		{Rules{Rows: 4, Columns: 12, Connect: 4}, true},
+		{Rules{Rows: 7, Columns: 8, Connect: 4, PopOut: true}, true},
+		{Rules{Rows: 9, Columns: 6, Connect: 9}, true}, // fits vertically
+
+		{Rules{}, false},
+		{Rules{Rows: 3, Columns: 7, Connect: 3}, false},
+		{Rules{Rows: 6, Columns: 3, Connect: 3}, false},
+		{Rules{Rows: 4, Columns: 13, Connect: 4}, false}, // too many columns
+		{Rules{Rows: 8, Columns: 8, Connect: 4}, false},  // 72 bits
+		{Rules{Rows: 6, Columns: 7, Connect: 2}, false},
+		{Rules{Rows: 6, Columns: 7, Connect: 8}, false},
+		{Rules{Rows: -6, Columns: 7, Connect: 4}, false},
+	}
+	for _, tc := range tests {
+		if err := tc.rules.Validate(); (err == nil) != tc.ok {
+			t.Errorf("%s: Validate() = %v, want ok %v", tc.rules, err, tc.ok)
+		}
+	}
+}
+
+func TestLargeBoardWins(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules Rules
+		moves []int // 0-based columns, alternately; only the last one wins
+		line  int   // length of the winning line
+	}{
+		{"7x8 horizontal", rules7x8, []int{4, 4, 5, 5, 6, 6, 7}, 4},
+		{"7x8 vertical", rules7x8, []int{0, 1, 0, 1, 0, 1, 0}, 4},
+		{"7x8 diagonal", rules7x8, []int{4, 5, 5, 6, 6, 7, 6, 7, 7, 0, 7}, 4},
+		{"6x9 four isn't enough", rules6x9, []int{0, 0, 1, 1, 2, 2, 3, 3, 4}, 5},
+		{"6x9 far column", rules6x9, []int{8, 7, 8, 7, 8, 7, 8, 7, 8}, 5},
+		{"6x9 diagonal", rules6x9, []int{0, 1, 2, 2, 3, 3, 1, 3, 2, 4, 4, 4, 3, 4, 4}, 5},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewGameWithRules("a", "b", tc.rules)
+			for i, col := range tc.moves {
+				want := "OK"
+				if i == len(tc.moves)-1 {
+					want = "WIN"
+				}
+				if result := MakeMove(g, col, g.Turn); result != want {
+					t.Fatalf("move %d (column %d): %s, want %s", i+1, col, result, want)
+				}
+			}
+			if g.Winner != 1 || len(g.WinningLines) != 1 || len(g.WinningLines[0]) != tc.line {
+				t.Errorf("winner %d with lines %v", g.Winner, g.WinningLines)
+			}
+			if p := GamePosition(g); !p.HasWon(1) || p.HasWon(2) {
+				t.Error("bitboard disagrees about the winner")
+			}
+		})
+	}
+
+	// The same four in a row does win on the classic board
+	if _, err := ParseMovesWithRules(ClassicRules, "1122334"); err == nil {
+		t.Error("four in a row didn't win with connect 4")
+	}
+	if _, err := ParseMovesWithRules(rules6x9, "1122334"); err != nil {
+		t.Errorf("four in a row ended a connect 5 game: %v", err)
+	}
+}
+
+func TestLargeBoardLegalMoves(t *testing.T) {
+	for _, rules := range []Rules{rules7x8, rules6x9} {
+		p := NewPositionWithRules(rules)
+		moves := p.LegalMoves()
+		if len(moves) != rules.Columns {
+			t.Fatalf("%s: %d moves on the empty board", rules, len(moves))
+		}
+		// Centre first, then outwards
+		for i := 1; i < len(moves); i++ {
+			if centreDistance(moves[i].Column, rules.Columns) < centreDistance(moves[i-1].Column, rules.Columns) {
+				t.Errorf("%s: moves not centre first: %v", rules, moves)
+				break
+			}
+		}
+
+		// Fill the last column; the colours alternate, so no line forms
+		last := rules.Columns - 1
+		for h := 0; h < rules.Rows; h++ {
+			if !p.CanPlay(last) {
+				t.Fatalf("%s: column full after %d discs", rules, h)
+			}
+			p.Play(last)
+		}
+		if p.CanPlay(last) {
+			t.Errorf("%s: room in column %d after %d discs", rules, last, rules.Rows)
+		}
+		for _, m := range p.LegalMoves() {
+			if m.Column == last {
+				t.Errorf("%s: full column %d is legal", rules, last)
+			}
+		}
+
+		g := NewGameWithRules("a", "b", rules)
+		if n := len(VariantOf(g).LegalMoves(g, 1)); n != rules.Columns {
+			t.Errorf("%s: game has %d legal moves, want %d", rules, n, rules.Columns)
+		}
+	}
+}
+
+func TestLargeBoardBots(t *testing.T) {
+	tests := []struct {
+		name   string
+		rules  Rules
+		moves  string
+		want   []int // acceptable 0-based columns
+		strong bool  // only ask the bots that see a move ahead
+	}{
+		{"7x8 takes the win", rules7x8, "223344", []int{0, 4}, false},
+		{"6x9 takes the win", rules6x9, "22334455", []int{0, 5}, false},
+		{"7x8 blocks", rules7x8, "21334", []int{4}, true},
+		{"6x9 blocks", rules6x9, "2133445", []int{5}, true},
+	}
+	for _, tc := range tests {
+		p, err := ParseMovesWithRules(tc.rules, tc.moves)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		for _, name := range []Difficulty{DifficultyBeginner, DifficultyCasual, DifficultyStrong, DifficultyMCTS, DifficultyExpert} {
+			if tc.strong && (name == DifficultyBeginner || name == DifficultyCasual) {
+				continue
+			}
+			bot, _ := LookupBot(name.BotName())
+			col := bot.ChooseMove(p, time.Now().Add(300*time.Millisecond))
+			if !p.CanPlay(col) {
+				t.Errorf("%s: %s played illegal column %d", tc.name, name, col)
+			} else if !containsInt(tc.want, col) {
+				t.Errorf("%s: %s played column %d, want one of %v", tc.name, name, col, tc.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package game
+
+import "time"
+
+// DefaultSearchDepth is the number of plies BotMove looks ahead
+const DefaultSearchDepth = 7
+
+// WinScore is the score of a won position; faster wins score higher
+const WinScore = 1000000
+
+// Search runs a negamax alpha-beta search for player on a board played
+// under rules and returns the best column and its score. Positive scores
+// favour player. A forced win scores WinScore plus the plies left unsearched
+// when it is found, so any score of WinScore or more is a win and faster
+// wins score higher; forced losses score -WinScore or less.
+func Search(rules Rules, board Board, player int, depth int) (int, int) {
+	return SearchPosition(PositionFromBoard(rules, board, player), depth)
+}
+
+// SearchPosition is Search on a bitboard, for the player to move in p.
+// Under PopOut rules the best move may be a pop, so use SearchMove there.
+func SearchPosition(p Position, depth int) (int, int) {
+	m, score := SearchMove(p, depth)
+	return m.Column, score
+}
+
+// SearchMove is SearchPosition returning the best Move, drop or pop. The
+// column is -1 if there is no legal move. Repetition draws are not seen.
+func SearchMove(p Position, depth int) (Move, int) {
+	return searchRoot(p, depth, nil)
+}
+
+// SearchMoveUntil is SearchMove searching one ply deeper at a time, up to
+// depth, and returning the result of the deepest search that finished
+// before deadline (zero means never). The one-ply search always finishes,
+// so there is a move even if the deadline has already passed.
+func SearchMoveUntil(p Position, depth int, deadline time.Time) (Move, int) {
+	best, score := searchRoot(p, 1, nil)
+	for d := 2; d <= depth && score < WinScore; d++ {
+		limit := &searchLimit{deadline: deadline}
+		m, s := searchRoot(p, d, limit)
+		if limit.aborted {
+			break
+		}
+		best, score = m, s
+	}
+	return best, score
+}
+
+// searchLimit stops a search once its deadline has passed. A nil limit or
+// a zero deadline never stops it.
+type searchLimit struct {
+	deadline time.Time
+	nodes    int
+	aborted  bool
+}
+
+// stop reports whether the search should give up, checking the clock
+// every few thousand nodes
+func (l *searchLimit) stop() bool {
+	if l == nil || l.deadline.IsZero() {
+		return false
+	}
+	l.nodes++
+	if l.nodes&0xfff == 0 && time.Now().After(l.deadline) {
+		l.aborted = true
+	}
+	return l.aborted
+}
+
+// searchRoot searches every move in p depth plies deep. If limit stops the
+// search the result is meaningless and limit.aborted is set.
+func searchRoot(p Position, depth int, limit *searchLimit) (Move, int) {
+	if depth < 1 {
+		depth = 1
+	}
+
+	best := Move{Column: -1}
+	alpha := -WinScore - depth - 1
+	beta := WinScore + depth + 1
+
+	for i := 0; i < p.candidateMoves(); i++ {
+		m := p.candidateMove(i)
+		if !p.CanPlayMove(m) {
+			continue
+		}
+
+		score := searchMove(p, m, depth, alpha, beta, limit)
+		if best.Column == -1 || score > alpha {
+			alpha = score
+			best = m
+		}
+	}
+
+	return best, alpha
+}
+
+// searchMove scores playing m for the player to move in p, searching the
+// reply depth-1 plies deep
+func searchMove(p Position, m Move, depth int, alpha, beta int, limit *searchLimit) int {
+	if !m.Pop && p.IsWinningMove(m.Column) {
+		return WinScore + depth
+	}
+
+	mover := p.Turn()
+	next := p
+	next.PlayMove(m)
+	if m.Pop {
+		// a pop can complete lines for both sides; the popper wins ties
+		switch {
+		case next.HasWon(mover):
+			return WinScore + depth
+		case next.HasWon(opponent(mover)):
+			return -WinScore - depth
+		}
+	}
+	return -negamax(next, depth-1, -beta, -alpha, limit)
+}
+
+// negamax scores p for the player to move, looking depth plies ahead
+func negamax(p Position, depth int, alpha, beta int, limit *searchLimit) int {
+	if limit.stop() {
+		return 0
+	}
+	// a full PopOut board isn't over, the player to move may still pop
+	if p.IsFull() && !p.geo.rules.PopOut {
+		return 0
+	}
+	if depth == 0 {
+		return evaluatePosition(p, p.Turn())
+	}
+
+	// Take an immediate win before searching anything else
+	for c := 0; c < p.Columns(); c++ {
+		if p.IsWinningMove(c) {
+			return WinScore + depth
+		}
+	}
+
+	moved := false
+	for i := 0; i < p.candidateMoves() && alpha < beta; i++ {
+		m := p.candidateMove(i)
+		if !p.CanPlayMove(m) {
+			continue
+		}
+		moved = true
+
+		if score := searchMove(p, m, depth, alpha, beta, limit); score > alpha {
+			alpha = score
+		}
+	}
+	if !moved {
+		return 0 // no drop or pop left, a draw
+	}
+
+	return alpha
+}
+
+// Evaluate scores a board played under rules for player by counting open
+// windows of Connect cells and rewarding discs in the centre column
+func Evaluate(rules Rules, board Board, player int) int {
+	return evaluatePosition(PositionFromBoard(rules, board, player), player)
+}
+
+// evaluatePosition is Evaluate on a bitboard
+func evaluatePosition(p Position, player int) int {
+	own := p.discs[player-1]
+	theirs := p.discs[opponent(player)-1]
+
+	score := 3 * (popCount(own&p.geo.centre) - popCount(theirs&p.geo.centre))
+	for _, w := range p.geo.windows {
+		score += scoreWindow(popCount(own&w), popCount(theirs&w), p.geo.rules.Connect)
+	}
+	return score
+}
+
+// scoreWindow scores a single window of connect cells. It must stay
+// symmetric (scoreWindow(a, b) == -scoreWindow(b, a)) for negamax to work.
+func scoreWindow(own, theirs, connect int) int {
+	if own > 0 && theirs > 0 {
+		return 0 // blocked for both sides
+	}
+	switch {
+	case own == connect-1:
+		return 5
+	case own == connect-2:
+		return 2
+	case theirs == connect-1:
+		return -5
+	case theirs == connect-2:
+		return -2
+	}
+	return 0
+}
+
+// opponent returns the other player number
+func opponent(player int) int {
+	if player == 1 {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,184 @@
+package game
+
+import "testing"
+
+// boardFromRows builds a board from six strings, top row first.
+// 'X' is player 1, 'O' is player 2 and '.' is empty.
+func boardFromRows(t testing.TB, rows [6]string) Board {
+	t.Helper()
+	board := NewBoard(ClassicRules)
+	for r, line := range rows {
+		if len(line) != 7 {
+			t.Fatalf("row %d has %d cells, want 7", r, len(line))
+		}
+		for c, ch := range line {
+			switch ch {
+			case 'X':
+				board[r][c] = 1
+			case 'O':
+				board[r][c] = 2
+			case '.':
+			default:
+				t.Fatalf("unexpected cell %q", ch)
+			}
+		}
+	}
+	return board
+}
+
+func TestSearchKnownPositions(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    [6]string
+		player  int
+		want    []int
+		wantWin bool
+	}{
+		{
+			name: "takes immediate horizontal win",
+			rows: [6]string{
+				".......",
+				".......",
+				".......",
+				".......",
+				"XX.....",
+				"XXOOO..",
+			},
+			player:  2,
+			want:    []int{5},
+			wantWin: true,
+		},
+		{
+			name: "blocks immediate vertical loss",
+			rows: [6]string{
+				".......",
+				".......",
+				".......",
+				"X......",
+				"X......",
+				"X..OO..",
+			},
+			player: 2,
+			want:   []int{0},
+		},
+		{
+			name: "creates double threat for forced win",
+			rows: [6]string{
+				".......",
+				".......",
+				".......",
+				".......",
+				"......X",
+				"X..OO.X",
+			},
+			player:  2,
+			want:    []int{2},
+			wantWin: true,
+		},
+		{
+			name: "prevents opponent double threat",
+			rows: [6]string{
+				".......",
+				".......",
+				".......",
+				".......",
+				".......",
+				"..XX..O",
+			},
+			player: 2,
+			want:   []int{1, 4},
+		},
+		{
+			name: "prefers diagonal win over blocking",
+			rows: [6]string{
+				".......",
+				".......",
+				".......",
+				"...XO..",
+				"..XOX..",
+				".XOOO.X",
+			},
+			player:  1,
+			want:    []int{4},
+			wantWin: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			board := boardFromRows(t, tt.rows)
+			col, score := Search(ClassicRules, board, tt.player, DefaultSearchDepth)
+
+			found := false
+			for _, w := range tt.want {
+				if col == w {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("Search chose column %d, want one of %v (score %d)", col, tt.want, score)
+			}
+			if tt.wantWin && score < WinScore {
+				t.Errorf("Search score = %d, want a forced win (>= %d)", score, WinScore)
+			}
+			if !tt.wantWin && score >= WinScore {
+				t.Errorf("Search score = %d, did not expect a forced win", score)
+			}
+		})
+	}
+}
+
+func TestSearchAvoidsForcedLoss(t *testing.T) {
+	// Player 1 threatens to play column 1 or 4 for an open three on the
+	// bottom row. Every other reply loses, so the searched score must not
+	// be a forced loss for the chosen move.
+	board := boardFromRows(t, [6]string{
+		".......",
+		".......",
+		".......",
+		".......",
+		".......",
+		"..XX..O",
+	})
+
+	for c := 0; c < 7; c++ {
+		tmp := board.Clone()
+		DropDisc(tmp, c, 2)
+		_, score := Search(ClassicRules, tmp, 1, DefaultSearchDepth-1)
+		losing := score >= WinScore
+		if (c == 1 || c == 4) == losing {
+			t.Errorf("column %d: opponent forced win = %v, score %d", c, losing, score)
+		}
+	}
+}
+
+func TestBotMoveWithDepthUsesTurn(t *testing.T) {
+	g := NewGame("alice", BotPlayerName)
+	g.Board = boardFromRows(t, [6]string{
+		".......",
+		".......",
+		".......",
+		"O......",
+		"O.....X",
+		"O....XX",
+	})
+	g.Turn = 1
+
+	if col := BotMoveWithDepth(g, 4); col != 0 {
+		t.Errorf("BotMoveWithDepth = %d, want block in column 0", col)
+	}
+}
+
+func TestEvaluateIsSymmetric(t *testing.T) {
+	board := boardFromRows(t, [6]string{
+		".......",
+		".......",
+		".......",
+		"...O...",
+		"..XX...",
+		".OXXO..",
+	})
+	if Evaluate(ClassicRules, board, 1) != -Evaluate(ClassicRules, board, 2) {
+		t.Errorf("Evaluate not zero-sum: %d vs %d", Evaluate(ClassicRules, board, 1), Evaluate(ClassicRules, board, 2))
+	}
+}
@@ -0,0 +1,36 @@
+package game
+
+import "math/rand"
+
+// Seat is the human's choice of turn order in a bot game
+type Seat string
+
+const (
+	SeatFirst  Seat = "first"  // human is Player1 and moves first
+	SeatSecond Seat = "second" // bot is Player1 and moves first
+	SeatRandom Seat = "random" // coin flip
+)
+
+// ParseSeat converts a query/message value into a Seat.
+// An empty string gives SeatRandom.
+func ParseSeat(s string) (Seat, bool) {
+	switch Seat(s) {
+	case "":
+		return SeatRandom, true
+	case SeatFirst, SeatSecond, SeatRandom:
+		return Seat(s), true
+	}
+	return "", false
+}
+
+// botPlayerFor returns the player number (1 or 2) the bot takes for seat,
+// flipping a coin for SeatRandom
+func botPlayerFor(seat Seat) int {
+	switch seat {
+	case SeatFirst:
+		return 2
+	case SeatSecond:
+		return 1
+	}
+	return rand.Intn(2) + 1
+}
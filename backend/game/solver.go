@@ -0,0 +1,326 @@
+package game
+
+import (
+	"sync"
+	"time"
+)
+
+// Solver score bounds. A score of s > 0 means the player to move wins with
+// their (22-s)th disc, s < 0 that they lose the same way, and 0 is a draw.
+const (
+	minSolveScore = -(6*7)/2 + 3
+	maxSolveScore = (6*7+1)/2 - 3
+)
+
+// ttSize is the number of transposition table entries. It must be a prime
+// above 2^17 so a 32-bit partial key plus the index identifies a position.
+const ttSize = 8388593
+
+// Outcome is the game-theoretic result of a position for the player to move
+type Outcome string
+
+const (
+	OutcomeWin  Outcome = "win"
+	OutcomeLoss Outcome = "loss"
+	OutcomeDraw Outcome = "draw"
+)
+
+// SolveResult is the exact value of a position with perfect play
+type SolveResult struct {
+	Score    int     `json:"score"`    // >0 win, <0 loss, 0 draw; bigger magnitude = sooner
+	Outcome  Outcome `json:"outcome"`  // result for the player to move
+	Distance int     `json:"distance"` // plies until the game ends, including the final move
+}
+
+// transpositionTable caches bounds found by the solver
+type transpositionTable struct {
+	keys   []uint32
+	values []uint8
+}
+
+func newTranspositionTable() *transpositionTable {
+	return &transpositionTable{
+		keys:   make([]uint32, ttSize),
+		values: make([]uint8, ttSize),
+	}
+}
+
+func (t *transpositionTable) put(key uint64, value uint8) {
+	i := key % ttSize
+	t.keys[i] = uint32(key)
+	t.values[i] = value
+}
+
+// get returns the stored value for key, or 0 if there is none
+func (t *transpositionTable) get(key uint64) uint8 {
+	i := key % ttSize
+	if t.keys[i] == uint32(key) {
+		return t.values[i]
+	}
+	return 0
+}
+
+// Solver finds exact scores with a null-window negamax search. It keeps its
+// transposition table between calls and is not safe for concurrent use.
+type Solver struct {
+	table    *transpositionTable
+	deadline time.Time
+	nodes    int
+	aborted  bool
+}
+
+// NewSolver allocates a solver with an empty transposition table
+func NewSolver() *Solver {
+	return &Solver{table: newTranspositionTable()}
+}
+
+var (
+	defaultSolver     *Solver
+	defaultSolverLock sync.Mutex
+)
+
+// Solve returns the exact value of p for the player to move. Only
+// ClassicRules positions can be solved; anything else returns a zero
+// SolveResult. Positions in the opening book are answered at once; others
+// are searched to the end, which takes seconds with 8 discs on the board
+// and minutes with 4.
+func Solve(p Position) SolveResult {
+	r, _ := SolveWithDeadline(p, time.Time{})
+	return r
+}
+
+// SolveWithDeadline is Solve but gives up at deadline (zero means never),
+// returning false if it ran out of time or p isn't a ClassicRules position
+func SolveWithDeadline(p Position, deadline time.Time) (SolveResult, bool) {
+	defaultSolverLock.Lock()
+	defer defaultSolverLock.Unlock()
+
+	if defaultSolver == nil {
+		defaultSolver = NewSolver()
+	}
+	return defaultSolver.Solve(p, deadline)
+}
+
+// Solve returns the exact value of p, or false if deadline passed first
+func (s *Solver) Solve(p Position, deadline time.Time) (SolveResult, bool) {
+	score, ok := s.score(p, deadline)
+	if !ok {
+		return SolveResult{}, false
+	}
+	return newSolveResult(p, score), true
+}
+
+// BestMove returns the column with the best exact score for the player to
+// move, or false if deadline passed first. Ties go to the most central column.
+func (s *Solver) BestMove(p Position, deadline time.Time) (int, SolveResult, bool) {
+	if p.geo != classicGeometry {
+		return -1, SolveResult{}, false
+	}
+	best := -1
+	bestScore := 0
+	for _, c := range p.geo.order {
+		if !p.CanPlay(c) {
+			continue
+		}
+		if p.IsWinningMove(c) {
+			return c, newSolveResult(p, (6*7+1-p.moves)/2), true
+		}
+
+		next := p
+		next.Play(c)
+		var score int
+		if next.IsFull() {
+			score = 0
+		} else {
+			childScore, ok := s.score(next, deadline)
+			if !ok {
+				return -1, SolveResult{}, false
+			}
+			score = -childScore
+		}
+
+		if best == -1 || score > bestScore {
+			best = c
+			bestScore = score
+		}
+	}
+	if best == -1 {
+		return -1, SolveResult{}, false
+	}
+	return best, newSolveResult(p, bestScore), true
+}
+
+// PerfectMove is Solver.BestMove on the shared solver
+func PerfectMove(p Position, deadline time.Time) (int, bool) {
+	defaultSolverLock.Lock()
+	defer defaultSolverLock.Unlock()
+
+	if defaultSolver == nil {
+		defaultSolver = NewSolver()
+	}
+	col, _, ok := defaultSolver.BestMove(p, deadline)
+	return col, ok
+}
+
+// score returns the raw solver score of p, checking the opening book first
+func (s *Solver) score(p Position, deadline time.Time) (int, bool) {
+	if p.geo != classicGeometry {
+		return 0, false // the table's partial keys and score encoding assume 6x7
+	}
+	if score, ok := lookupBook(p); ok {
+		return score, true
+	}
+	if p.CanWinNext() {
+		return (6*7 + 1 - p.moves) / 2, true
+	}
+	if p.IsFull() {
+		return 0, true
+	}
+
+	s.deadline = deadline
+	s.nodes = 0
+	s.aborted = false
+
+	// Narrow the score window with null-window searches
+	min := -(6*7 - p.moves) / 2
+	max := (6*7 + 1 - p.moves) / 2
+	for min < max {
+		med := min + (max-min)/2
+		if med <= 0 && min/2 < med {
+			med = min / 2
+		} else if med >= 0 && max/2 > med {
+			med = max / 2
+		}
+		r := s.negamax(p, med, med+1)
+		if s.aborted {
+			return 0, false
+		}
+		if r <= med {
+			max = r
+		} else {
+			min = r
+		}
+	}
+	return min, true
+}
+
+// negamax returns the score of p within [alpha, beta]. The player to move
+// must not have an immediate win.
+func (s *Solver) negamax(p Position, alpha, beta int) int {
+	s.nodes++
+	if s.nodes&0xfff == 0 && !s.deadline.IsZero() && time.Now().After(s.deadline) {
+		s.aborted = true
+	}
+	if s.aborted {
+		return 0
+	}
+	if score, ok := lookupBook(p); ok {
+		return score
+	}
+
+	next := p.nonLosingMoves()
+	if next == 0 {
+		return -(6*7 - p.moves) / 2 // every move hands over a win
+	}
+	if p.moves >= 6*7-2 {
+		return 0 // draw, neither side can win with the last two discs
+	}
+
+	min := -(6*7 - 2 - p.moves) / 2
+	if alpha < min {
+		alpha = min
+		if alpha >= beta {
+			return alpha
+		}
+	}
+	max := (6*7 - 1 - p.moves) / 2
+
+	key := p.Key()
+	if val := int(s.table.get(key)); val != 0 {
+		if val > maxSolveScore-minSolveScore+1 { // lower bound
+			min = val + 2*minSolveScore - maxSolveScore - 2
+			if alpha < min {
+				alpha = min
+				if alpha >= beta {
+					return alpha
+				}
+			}
+		} else { // upper bound
+			max = val + minSolveScore - 1
+		}
+	}
+	if beta > max {
+		beta = max
+		if alpha >= beta {
+			return beta
+		}
+	}
+
+	// Order moves by how many winning cells they create, centre first on ties
+	var moves [7]int
+	var scores [7]int
+	n := 0
+	for _, c := range p.geo.order {
+		move := next & p.geo.columnMask(c)
+		if move == 0 {
+			continue
+		}
+		score := p.moveScore(move)
+		i := n
+		for ; i > 0 && scores[i-1] < score; i-- {
+			moves[i] = moves[i-1]
+			scores[i] = scores[i-1]
+		}
+		moves[i] = c
+		scores[i] = score
+		n++
+	}
+
+	for i := 0; i < n; i++ {
+		child := p
+		child.Play(moves[i])
+		score := -s.negamax(child, -beta, -alpha)
+		if s.aborted {
+			return 0
+		}
+		if score >= beta {
+			s.table.put(key, uint8(score+maxSolveScore-2*minSolveScore+2))
+			return score
+		}
+		if score > alpha {
+			alpha = score
+		}
+	}
+
+	s.table.put(key, uint8(alpha-minSolveScore+1))
+	return alpha
+}
+
+// newSolveResult converts a raw solver score into a SolveResult
+func newSolveResult(p Position, score int) SolveResult {
+	r := SolveResult{Score: score}
+	switch {
+	case score > 0:
+		r.Outcome = OutcomeWin
+		r.Distance = winDistance(p.moves, p.moves, score)
+	case score < 0:
+		r.Outcome = OutcomeLoss
+		r.Distance = winDistance(p.moves, p.moves+1, -score)
+	default:
+		r.Outcome = OutcomeDraw
+		r.Distance = 6*7 - p.moves
+	}
+	return r
+}
+
+// winDistance converts a winner's score into plies from the current
+// position. winnerParity is the move count at which the winner is to move.
+func winDistance(moves, winnerParity, score int) int {
+	// The winner plays their last disc when 6*7+1-2*score discs are on the
+	// board, rounded down to their own parity
+	before := 6*7 + 1 - 2*score
+	if (before-winnerParity)%2 != 0 {
+		before--
+	}
+	return before - moves + 1
+}
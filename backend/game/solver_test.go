@@ -0,0 +1,147 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+// testSolver is shared by the solver tests; allocating its table is slow
+var testSolver = NewSolver()
+
+func TestSolveKnownScores(t *testing.T) {
+	tests := []struct {
+		name     string
+		moves    string
+		rows     *[6]string // instead of moves, with player 1 to move
+		score    int
+		outcome  Outcome
+		distance int
+	}{
+		// From the opening book
+		{name: "empty board", moves: "", score: 1, outcome: OutcomeWin, distance: 41},
+		{name: "centre opening", moves: "4", score: -1, outcome: OutcomeLoss, distance: 40},
+		{name: "edge opening", moves: "1", score: 2, outcome: OutcomeWin, distance: 39},
+
+		// Built by hand
+		{name: "three in a row to complete", moves: "445566", score: 18, outcome: OutcomeWin, distance: 1},
+		{name: "open three can't be stopped", moves: "44553", score: -18, outcome: OutcomeLoss, distance: 2},
+		{name: "last disc draws", rows: &drawRows, score: 0, outcome: OutcomeDraw, distance: 1},
+
+		// From Pascal Pons' solver benchmark positions
+		{name: "pons end 1", moves: "2252576253462244111563365343671351441", score: -1, outcome: OutcomeLoss},
+		{name: "pons end 2", moves: "7422341735647741166133573473242566", score: 1, outcome: OutcomeWin},
+		{name: "pons end 3", moves: "23163416124767223154467471272416755633", score: 0, outcome: OutcomeDraw},
+		{name: "pons end 4", moves: "65214673556155731566316327373221417", score: -1, outcome: OutcomeLoss},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p Position
+			if tt.rows != nil {
+				p = PositionFromBoard(ClassicRules, boardFromRows(t, *tt.rows), 1)
+			} else {
+				var err error
+				if p, err = ParseMoves(tt.moves); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			r, ok := testSolver.Solve(p, time.Now().Add(10*time.Second))
+			if !ok {
+				t.Fatal("not solved in 10s")
+			}
+			if r.Score != tt.score || r.Outcome != tt.outcome {
+				t.Errorf("Solve = %d (%s), want %d (%s)", r.Score, r.Outcome, tt.score, tt.outcome)
+			}
+			if tt.distance != 0 && r.Distance != tt.distance {
+				t.Errorf("Distance = %d, want %d", r.Distance, tt.distance)
+			}
+		})
+	}
+}
+
+func TestPerfectMoveForcedWin(t *testing.T) {
+	tests := []struct {
+		name  string
+		moves string
+		want  []int // any of these columns (0-based)
+	}{
+		// Ties go to the most central winning column
+		{name: "completes the three", moves: "445566", want: []int{2}},
+		{name: "keeps a win to the last disc", moves: "7422341735647741166133573473242566"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParseMoves(tt.moves)
+			if err != nil {
+				t.Fatal(err)
+			}
+			col, r, ok := testSolver.BestMove(p, time.Now().Add(30*time.Second))
+			if !ok {
+				t.Fatal("not solved in 30s")
+			}
+			if r.Outcome != OutcomeWin {
+				t.Fatalf("BestMove outcome %s, want a win", r.Outcome)
+			}
+			if tt.want != nil && !containsInt(tt.want, col) {
+				t.Errorf("BestMove = %d, want one of %v", col, tt.want)
+			}
+
+			// The move keeps the win: it wins at once or the opponent loses
+			if p.IsWinningMove(col) {
+				return
+			}
+			next := p
+			next.Play(col)
+			if after, _ := testSolver.Solve(next, time.Time{}); after.Outcome != OutcomeLoss {
+				t.Errorf("after column %d the opponent's outcome is %s, want loss", col, after.Outcome)
+			}
+		})
+	}
+}
+
+func TestExpertBotMeetsDeadline(t *testing.T) {
+	// Early positions outside the book can't be solved in time; the bot
+	// must still answer by the deadline
+	for _, moves := range []string{"4", "44", "43"} {
+		p, err := ParseMoves(moves)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := time.Now()
+		col := solverBot{}.ChooseMove(p, start.Add(300*time.Millisecond))
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("after %s the bot took %v with a 300ms deadline", moves, elapsed)
+		}
+		if !p.CanPlay(col) {
+			t.Errorf("after %s the bot played full or missing column %d", moves, col)
+		}
+	}
+}
+
+func TestSearchMoveUntilStopsAtDeadline(t *testing.T) {
+	p := NewPosition()
+	start := time.Now()
+	m, _ := SearchMoveUntil(p, 40, start.Add(100*time.Millisecond))
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Errorf("a 100ms search took %v", elapsed)
+	}
+	if !p.CanPlay(m.Column) {
+		t.Errorf("SearchMoveUntil = %+v, want a legal move", m)
+	}
+
+	// A passed deadline still gets a move from the one-ply search
+	if m, _ := SearchMoveUntil(p, 40, start.Add(-time.Second)); !p.CanPlay(m.Column) {
+		t.Errorf("SearchMoveUntil after the deadline = %+v, want a legal move", m)
+	}
+}
+
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
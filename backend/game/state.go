@@ -3,22 +3,64 @@ package game
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"sync"
 	"time"
 )
 
+// Game is one game in play. Once a game is shared between goroutines (it
+// has been returned by the matchmaker or a rematch), everything that reads
+// or changes it must hold its lock; the functions in this package leave
+// locking to the caller.
 type Game struct {
+	mu sync.Mutex
+
 	ID       string
 	Player1  string
 	Player2  string
 	Turn     int
-	Board    [6][7]int
+	Board    Board
+	Rules    Rules   // Board size and win length
+	Variant  Variant // Move and win rules, classic if nil
 	GameOver bool
 	Winner   int
 
+	EndReason    EndReason // How the game ended, empty while it is running
+	WinningLines [][]Cell  // Winner's lines of Connect or more discs, nil for draws and forfeits
+
+	TimeControl  TimeControl      // Untimed if zero
+	Clocks       [2]time.Duration // Time left for players 1 and 2 as of ClockStarted
+	ClockStarted time.Time        // When the player to move's clock started running
+
+	Difficulty Difficulty // Bot strength, only set for bot games
+	BotName    string     // Registered Bot in this game, empty for human games
+	BotPlayer  int        // Which player (1 or 2) the bot is, 0 for human games
+	Practice   bool       // Bot games only: takebacks are granted without asking
+	BotBusy    bool       // A goroutine is playing the bot's turn
+
+	PositionCounts map[string]int      // PopOut only: times each position (board string and side to move) has occurred
+	Powers         map[int][]PowerDisc // Power Up only: power discs each player has left
+
+	History []MoveRecord // Every move played, oldest first
+	undone  []MoveRecord // Moves taken back by Undo, most recent last
+
+	TakebackRequest int // Player waiting for an answer to a takeback request, 0 if none
+	DrawOffer       int // Player whose draw offer is pending, 0 if none
+	RematchRequest  int // Player waiting for an answer to a rematch request, 0 if none
+
+	Series Series // Results of the earlier games in this series of rematches
+	next   *Game  // The rematch, once one is agreed
+	Saving bool   // The result is being saved; no rematch until it is announced
+
 	LastSeen    map[string]time.Time
 	Connections map[string]bool // Track active connections
 }
 
+// Lock takes g's lock, serializing access to the game
+func (g *Game) Lock() { g.mu.Lock() }
+
+// Unlock releases g's lock
+func (g *Game) Unlock() { g.mu.Unlock() }
+
 // GenerateGameID creates a unique game ID
 func GenerateGameID() string {
 	bytes := make([]byte, 8)
@@ -0,0 +1,73 @@
+package game
+
+import "errors"
+
+// RequestTakeback records that player wants their last move back. The
+// opponent answers with AcceptTakeback or DeclineTakeback; a move by either
+// player withdraws the request.
+func RequestTakeback(g *Game, player int) error {
+	if g.GameOver {
+		return errors.New("Game already finished")
+	}
+	if !hasMoved(g, player) {
+		return errors.New("No move to take back")
+	}
+	if g.TakebackRequest != 0 {
+		return errors.New("Takeback already requested")
+	}
+	g.TakebackRequest = player
+	return nil
+}
+
+// AcceptTakeback grants the opponent's pending request, taking back their
+// last move and any moves played since
+func AcceptTakeback(g *Game, player int) error {
+	if g.GameOver {
+		return errors.New("Game already finished")
+	}
+	if g.TakebackRequest != opponent(player) {
+		return errors.New("No takeback to accept")
+	}
+	g.TakebackRequest = 0
+	return Takeback(g, opponent(player))
+}
+
+// DeclineTakeback refuses the opponent's pending request
+func DeclineTakeback(g *Game, player int) error {
+	if g.TakebackRequest != opponent(player) {
+		return errors.New("No takeback to decline")
+	}
+	g.TakebackRequest = 0
+	return nil
+}
+
+// Takeback undoes moves until player's last turn is taken back, so it is
+// their move again. Extra moves from the same turn (a Power Up double) are
+// taken back together. A finished game can't be taken back: its result
+// has already been saved.
+func Takeback(g *Game, player int) error {
+	if g.GameOver {
+		return errors.New("Game already finished")
+	}
+	if !hasMoved(g, player) {
+		return errors.New("No move to take back")
+	}
+	for g.History[len(g.History)-1].Player != player {
+		Undo(g)
+	}
+	for len(g.History) > 0 && g.History[len(g.History)-1].Player == player {
+		Undo(g)
+	}
+	g.TakebackRequest = 0
+	return nil
+}
+
+// hasMoved reports whether player has a move in g.History
+func hasMoved(g *Game, player int) bool {
+	for _, r := range g.History {
+		if r.Player == player {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package game
+
+import "testing"
+
+func TestTakebackAfterGameOver(t *testing.T) {
+	tests := []struct {
+		name string
+		end  func(g *Game) // ends the game after player 1's takeback request
+	}{
+		{
+			name: "win",
+			end: func(g *Game) {
+				for _, col := range []int{1, 6, 1, 6, 1, 6, 1} {
+					MakeMove(g, col, g.Turn)
+				}
+			},
+		},
+		{
+			name: "resignation",
+			end:  func(g *Game) { Resign(g, 2) },
+		},
+		{
+			name: "forfeit",
+			end:  func(g *Game) { ForfeitGame(g, "bob") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGame("alice", "bob")
+			MakeMove(g, 0, 1)
+			MakeMove(g, 0, 2)
+			if err := RequestTakeback(g, 1); err != nil {
+				t.Fatal(err)
+			}
+			tt.end(g)
+			if !g.GameOver {
+				t.Fatal("game not over")
+			}
+			played := len(g.History)
+
+			if err := AcceptTakeback(g, 2); err == nil {
+				t.Error("AcceptTakeback succeeded after the game ended")
+			}
+			if err := Takeback(g, 1); err == nil {
+				t.Error("Takeback succeeded after the game ended")
+			}
+			if err := RequestTakeback(g, 2); err == nil {
+				t.Error("RequestTakeback succeeded after the game ended")
+			}
+			if !g.GameOver || len(g.History) != played {
+				t.Errorf("GameOver = %v with %d moves, want the finished game of %d moves", g.GameOver, len(g.History), played)
+			}
+		})
+	}
+}
+
+func TestTakeback(t *testing.T) {
+	g := NewGame("alice", "bob")
+	MakeMove(g, 3, 1)
+	MakeMove(g, 4, 2)
+	MakeMove(g, 3, 1)
+
+	// Player 2 takes back their move, and player 1's reply with it
+	if err := RequestTakeback(g, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := AcceptTakeback(g, 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.History) != 1 || g.Turn != 2 || g.TakebackRequest != 0 {
+		t.Errorf("after takeback: %d moves, turn %d, request %d, want 1 move, turn 2, no request", len(g.History), g.Turn, g.TakebackRequest)
+	}
+	if err := AcceptTakeback(g, 1); err == nil {
+		t.Error("AcceptTakeback succeeded with no request pending")
+	}
+}
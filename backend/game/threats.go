@@ -0,0 +1,118 @@
+package game
+
+// Direction is a line along which a winning row can be made
+type Direction string
+
+const (
+	Horizontal   Direction = "horizontal"
+	Vertical     Direction = "vertical"
+	DiagonalUp   Direction = "diagonal_up"   // bottom-left to top-right
+	DiagonalDown Direction = "diagonal_down" // top-left to bottom-right
+)
+
+// directionSteps maps each direction to its (row, column) step on the board
+var directionSteps = []struct {
+	dir      Direction
+	row, col int
+}{
+	{Horizontal, 0, 1},
+	{Vertical, 1, 0},
+	{DiagonalUp, -1, 1},
+	{DiagonalDown, 1, 1},
+}
+
+// Threat is an empty cell that would complete a winning line for a player
+type Threat struct {
+	Row        int         `json:"row"`        // board row, 0 at the top
+	Column     int         `json:"column"`     // board column, 0 at the left
+	Directions []Direction `json:"directions"` // lines completed by playing here
+	Playable   bool        `json:"playable"`   // the next disc in this column lands here
+	Odd        bool        `json:"odd"`        // odd row counted from 1 at the bottom
+}
+
+// ThreatAnalysis summarises one player's threats. In zugzwang play the first
+// player (1) profits from odd threats and the second player (2) from even
+// ones, because those are the cells the opponent is eventually forced to
+// fill underneath.
+type ThreatAnalysis struct {
+	Player      int               `json:"player"`
+	Threats     []Threat          `json:"threats"`     // every threat, top-left first
+	Immediate   int               `json:"immediate"`   // threats that can be played right now
+	Future      int               `json:"future"`      // threats waiting for the column to fill up
+	Odd         int               `json:"odd"`         // threats on odd rows
+	Even        int               `json:"even"`        // threats on even rows
+	Good        int               `json:"good"`        // future threats on the player's favoured parity
+	ByDirection map[Direction]int `json:"byDirection"` // threats along each direction
+}
+
+// AnalyzeThreats finds every cell where player would complete connect in a
+// row. Runs blocked at both ends are not threats, so they aren't counted.
+func AnalyzeThreats(board Board, player int, connect int) ThreatAnalysis {
+	a := ThreatAnalysis{
+		Player:      player,
+		Threats:     []Threat{},
+		ByDirection: make(map[Direction]int),
+	}
+
+	rows := board.Rows()
+	for row := 0; row < rows; row++ {
+		for col := 0; col < board.Columns(); col++ {
+			if board[row][col] != 0 {
+				continue
+			}
+
+			var dirs []Direction
+			for _, d := range directionSteps {
+				if lineLength(board, row, col, d.row, d.col, player) >= connect {
+					dirs = append(dirs, d.dir)
+				}
+			}
+			if len(dirs) == 0 {
+				continue
+			}
+
+			t := Threat{
+				Row:        row,
+				Column:     col,
+				Directions: dirs,
+				Playable:   row == rows-1 || board[row+1][col] != 0,
+				Odd:        (rows-row)%2 == 1,
+			}
+			a.Threats = append(a.Threats, t)
+
+			if t.Playable {
+				a.Immediate++
+			} else {
+				a.Future++
+				if t.Odd == (player == 1) {
+					a.Good++
+				}
+			}
+			if t.Odd {
+				a.Odd++
+			} else {
+				a.Even++
+			}
+			for _, d := range dirs {
+				a.ByDirection[d]++
+			}
+		}
+	}
+
+	return a
+}
+
+// lineLength counts the run of player's discs through (row, col) along one
+// direction, treating the empty cell itself as player's
+func lineLength(board Board, row, col, dr, dc, player int) int {
+	count := 1
+	for _, sign := range [2]int{1, -1} {
+		r, c := row+sign*dr, col+sign*dc
+		for r >= 0 && r < board.Rows() && c >= 0 && c < board.Columns() && board[r][c] == player {
+			count++
+			r += sign * dr
+			c += sign * dc
+		}
+	}
+	return count
+}
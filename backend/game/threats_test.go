@@ -0,0 +1,157 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeThreats(t *testing.T) {
+	tests := []struct {
+		name   string
+		rows   [6]string
+		player int
+		want   ThreatAnalysis
+	}{
+		{
+			name:   "open three on the bottom row",
+			rows:   [6]string{".......", ".......", ".......", ".......", "..OO...", ".XXX..."},
+			player: 1,
+			want: ThreatAnalysis{
+				Player: 1,
+				Threats: []Threat{
+					{Row: 5, Column: 0, Directions: []Direction{Horizontal}, Playable: true, Odd: true},
+					{Row: 5, Column: 4, Directions: []Direction{Horizontal}, Playable: true, Odd: true},
+				},
+				Immediate:   2,
+				Odd:         2,
+				ByDirection: map[Direction]int{Horizontal: 2},
+			},
+		},
+		{
+			name:   "two in a row isn't a threat",
+			rows:   [6]string{".......", ".......", ".......", ".......", "..OO...", ".XXX..."},
+			player: 2,
+			want:   ThreatAnalysis{Player: 2, Threats: []Threat{}, ByDirection: map[Direction]int{}},
+		},
+		{
+			name:   "odd future threat is good for player 1",
+			rows:   [6]string{".......", ".......", ".......", "XXX....", "OOX....", "XOO.O.."},
+			player: 1,
+			want: ThreatAnalysis{
+				Player: 1,
+				Threats: []Threat{
+					{Row: 3, Column: 3, Directions: []Direction{Horizontal}, Odd: true},
+				},
+				Future:      1,
+				Odd:         1,
+				Good:        1,
+				ByDirection: map[Direction]int{Horizontal: 1},
+			},
+		},
+		{
+			name:   "player 2's gap in the bottom row",
+			rows:   [6]string{".......", ".......", ".......", "XXX....", "OOX....", "XOO.O.."},
+			player: 2,
+			want: ThreatAnalysis{
+				Player: 2,
+				Threats: []Threat{
+					{Row: 5, Column: 3, Directions: []Direction{Horizontal}, Playable: true, Odd: true},
+				},
+				Immediate:   1,
+				Odd:         1,
+				ByDirection: map[Direction]int{Horizontal: 1},
+			},
+		},
+		{
+			name:   "even future threat is good for player 2",
+			rows:   [6]string{".......", ".......", "OOO....", "XXO....", "OXX....", "XOX.X.."},
+			player: 2,
+			want: ThreatAnalysis{
+				Player: 2,
+				Threats: []Threat{
+					{Row: 2, Column: 3, Directions: []Direction{Horizontal}},
+				},
+				Future:      1,
+				Even:        1,
+				Good:        1,
+				ByDirection: map[Direction]int{Horizontal: 1},
+			},
+		},
+		{
+			name:   "even future threat isn't good for player 1",
+			rows:   [6]string{".......", ".......", "XXX....", "OOX....", "XOO....", "OXO.O.."},
+			player: 1,
+			want: ThreatAnalysis{
+				Player: 1,
+				Threats: []Threat{
+					{Row: 2, Column: 3, Directions: []Direction{Horizontal}},
+				},
+				Future:      1,
+				Even:        1,
+				ByDirection: map[Direction]int{Horizontal: 1},
+			},
+		},
+		{
+			name:   "one cell completes two lines",
+			rows:   [6]string{".......", ".......", ".......", "..XX...", ".XOX..O", "XOOXO.O"},
+			player: 1,
+			want: ThreatAnalysis{
+				Player: 1,
+				Threats: []Threat{
+					{Row: 2, Column: 3, Directions: []Direction{Vertical, DiagonalUp}, Playable: true},
+				},
+				Immediate:   1,
+				Even:        1,
+				ByDirection: map[Direction]int{Vertical: 1, DiagonalUp: 1},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := AnalyzeThreats(boardFromRows(t, tc.rows), tc.player, 4)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tc.want)
+			}
+
+			// The mirror image has the same counts, with the diagonals
+			// swapped
+			var mirrored [6]string
+			for r, line := range tc.rows {
+				b := []byte(line)
+				for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+					b[i], b[j] = b[j], b[i]
+				}
+				mirrored[r] = string(b)
+			}
+			m := AnalyzeThreats(boardFromRows(t, mirrored), tc.player, 4)
+			if m.Immediate != got.Immediate || m.Future != got.Future || m.Odd != got.Odd || m.Even != got.Even || m.Good != got.Good {
+				t.Errorf("mirror image: %+v", m)
+			}
+			for _, d := range []Direction{Horizontal, Vertical, DiagonalUp, DiagonalDown} {
+				md := d
+				switch d {
+				case DiagonalUp:
+					md = DiagonalDown
+				case DiagonalDown:
+					md = DiagonalUp
+				}
+				if m.ByDirection[md] != got.ByDirection[d] {
+					t.Errorf("mirror image: %d %s threats, want %d", m.ByDirection[md], md, got.ByDirection[d])
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzeThreatsConnectFive(t *testing.T) {
+	board, _, err := ParseBoard("9/9/9/9/9/1xxxx4 o")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := AnalyzeThreats(board, 1, 5); len(a.Threats) != 2 || a.Immediate != 2 || a.ByDirection[Horizontal] != 2 {
+		t.Errorf("four in a row with connect 5: %+v", a)
+	}
+	if a := AnalyzeThreats(board, 1, 6); len(a.Threats) != 0 {
+		t.Errorf("four in a row with connect 6: %+v", a)
+	}
+}
@@ -0,0 +1,226 @@
+package game
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Variant is a rule set: which moves are legal, what they do to the board
+// and when the game ends. PlayMove drives a game through its Variant, so a
+// new variant only needs to implement this and call RegisterVariant.
+type Variant interface {
+	// Name is the registry key and the "variant" query value, e.g. "classic"
+	Name() string
+	// Rules is the board the variant is played on unless the player asks
+	// for another size
+	Rules() Rules
+	// Setup prepares a new game after its board is created, e.g. by
+	// pre-filling columns
+	Setup(g *Game)
+	// LegalMoves lists every move player could make in g
+	LegalMoves(g *Game, player int) []Move
+	// ApplyMove plays m for player on g, or returns why it is illegal
+	// without changing anything. It reports whether player moves again.
+	ApplyMove(g *Game, m Move, player int) (bool, error)
+	// Result reports whether the game is over after player's move and the
+	// winner, 0 for a draw. next is the player due to move if it isn't.
+	Result(g *Game, player, next int) (bool, int)
+}
+
+// DefaultVariant is used when a player doesn't ask for one
+const DefaultVariant = "classic"
+
+var (
+	variantRegistry     = make(map[string]Variant)
+	variantRegistryLock sync.RWMutex
+)
+
+func init() {
+	RegisterVariant(classicVariant{})
+	RegisterVariant(popOutVariant{})
+	RegisterVariant(fiveVariant{})
+	RegisterVariant(powerUpVariant{})
+}
+
+// RegisterVariant adds a variant to the registry, replacing any variant
+// with the same name
+func RegisterVariant(v Variant) {
+	variantRegistryLock.Lock()
+	defer variantRegistryLock.Unlock()
+	variantRegistry[v.Name()] = v
+}
+
+// LookupVariant finds a registered variant by name. An empty name gives
+// DefaultVariant.
+func LookupVariant(name string) (Variant, bool) {
+	if name == "" {
+		name = DefaultVariant
+	}
+	variantRegistryLock.RLock()
+	defer variantRegistryLock.RUnlock()
+	v, ok := variantRegistry[name]
+	return v, ok
+}
+
+// VariantNames lists the registered variants in alphabetical order
+func VariantNames() []string {
+	variantRegistryLock.RLock()
+	defer variantRegistryLock.RUnlock()
+	names := make([]string, 0, len(variantRegistry))
+	for name := range variantRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// VariantOf returns the variant g is played with, classic if none is set
+func VariantOf(g *Game) Variant {
+	if g.Variant == nil {
+		return classicVariant{}
+	}
+	return g.Variant
+}
+
+// PlayMove plays m for player with g's variant, runs the clocks and
+// records the move in g.History, withdrawing any pending takeback request
+// and any draw offer from the opponent.
+// It returns "OK", "WIN" (the game ended with g.Winner set), "DRAW", or why
+// the move was rejected.
+func PlayMove(g *Game, m Move, player int) string {
+	if !g.GameOver && g.Turn == player && g.TimeControl.Timed() && TimeLeft(g, player) == 0 {
+		return "Out of time"
+	}
+	before := takeSnapshot(g)
+	stopClock(g)
+	result := applyMove(g, m, player)
+	if result == "OK" || result == "WIN" || result == "DRAW" {
+		recordMove(g, MoveRecord{
+			Player: player,
+			Move:   m,
+			Time:   time.Now(),
+			Result: result,
+			before: before,
+		})
+		passClock(g, player)
+		g.TakebackRequest = 0
+		if g.DrawOffer != player {
+			g.DrawOffer = 0
+		}
+	}
+	return result
+}
+
+// applyMove is PlayMove without the history
+func applyMove(g *Game, m Move, player int) string {
+
+	if g.GameOver {
+		return "Game already finished"
+	}
+
+	if g.Turn != player {
+		return "Not your turn"
+	}
+
+	if m.Column < 0 || m.Column >= g.Rules.Columns {
+		return "Invalid column"
+	}
+
+	v := VariantOf(g)
+	again, err := v.ApplyMove(g, m, player)
+	if err != nil {
+		return err.Error()
+	}
+
+	next := opponent(player)
+	if again {
+		next = player
+	}
+
+	if over, winner := v.Result(g, player, next); over {
+		g.GameOver = true
+		g.Winner = winner // 0 = draw
+		g.EndReason = moveEndReason(g)
+		if winner == 0 {
+			return "DRAW"
+		}
+		g.WinningLines = FindWinningLines(g.Board, winner, g.Rules.Connect)
+		return "WIN"
+	}
+
+	g.Turn = next
+	return "OK"
+}
+
+// lineWinner returns the player with a winning line, checking player first
+// so they win when a move completes lines for both sides, or 0 if nobody
+// has one
+func lineWinner(g *Game, player int) int {
+	for _, p := range []int{player, opponent(player)} {
+		if CheckWin(g.Board, p, g.Rules.Connect) {
+			return p
+		}
+	}
+	return 0
+}
+
+// classicVariant is standard Connect Four on any board size
+type classicVariant struct{}
+
+func (classicVariant) Name() string { return "classic" }
+
+func (classicVariant) Rules() Rules { return ClassicRules }
+
+func (classicVariant) Setup(g *Game) {}
+
+func (classicVariant) LegalMoves(g *Game, player int) []Move {
+	var moves []Move
+	for c := 0; c < g.Rules.Columns; c++ {
+		if g.Board[0][c] == 0 {
+			moves = append(moves, Move{Column: c})
+		}
+	}
+	return moves
+}
+
+func (classicVariant) ApplyMove(g *Game, m Move, player int) (bool, error) {
+	if m.Pop {
+		return false, errors.New("Popping is only allowed in PopOut")
+	}
+	if m.Power != "" {
+		return false, errors.New("Power discs are only allowed in Power Up")
+	}
+	if !DropDisc(g.Board, m.Column, player) {
+		return false, errors.New("Column full")
+	}
+	return false, nil
+}
+
+func (classicVariant) Result(g *Game, player, next int) (bool, int) {
+	if winner := lineWinner(g, player); winner != 0 {
+		return true, winner
+	}
+	return CheckDraw(g.Board), 0
+}
+
+// fiveVariant is Five-in-a-Row: a 6x9 board whose outer columns start
+// filled with alternating discs, won with five in a row
+type fiveVariant struct {
+	classicVariant
+}
+
+func (fiveVariant) Name() string { return "five" }
+
+func (fiveVariant) Rules() Rules { return Rules{Rows: 6, Columns: 9, Connect: 5} }
+
+func (fiveVariant) Setup(g *Game) {
+	last := g.Rules.Columns - 1
+	for row := range g.Board {
+		// the bottom disc is player 1's on the left and player 2's on the right
+		left := 1 + (g.Rules.Rows-1-row)%2
+		g.Board[row][0] = left
+		g.Board[row][last] = opponent(left)
+	}
+}
@@ -0,0 +1,103 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+// newFiveGame returns a new Five-in-a-Row game
+func newFiveGame(t *testing.T) *Game {
+	t.Helper()
+	five, ok := LookupVariant("five")
+	if !ok {
+		t.Fatal("five isn't registered")
+	}
+	return NewGameWithVariant("alice", "bob", five, five.Rules())
+}
+
+func TestFiveInARowSetup(t *testing.T) {
+	g := newFiveGame(t)
+	if g.Rules != (Rules{Rows: 6, Columns: 9, Connect: 5}) {
+		t.Errorf("rules %s, want 6x9 connect 5", g.Rules)
+	}
+	// The outer columns alternate, player 1 at the bottom on the left
+	if got, want := FormatBoard(g.Board, g.Turn), "o7x/x7o/o7x/x7o/o7x/x7o x"; got != want {
+		t.Errorf("starting board %q, want %q", got, want)
+	}
+	for _, col := range []int{0, 8} {
+		if got := MakeMove(g, col, 1); got != "Column full" {
+			t.Errorf("column %d: %q", col, got)
+		}
+	}
+	moves := VariantOf(g).LegalMoves(g, 1)
+	if len(moves) != 7 {
+		t.Errorf("%d legal moves, want the 7 inner columns", len(moves))
+	}
+	// The notation can't describe a game that starts with discs down
+	if moves, ok := GameMoves(g); ok {
+		t.Errorf("GameMoves = %q", moves)
+	}
+}
+
+func TestFiveInARowWins(t *testing.T) {
+	g := newFiveGame(t)
+	// Player 1's four in the bottom row, columns 4 to 7, isn't a win: the
+	// pre-filled disc next to them is player 2's
+	for i, col := range []int{4, 4, 5, 5, 6, 6, 7, 1} {
+		if got := MakeMove(g, col, g.Turn); got != "OK" {
+			t.Fatalf("move %d (column %d): %q", i+1, col, got)
+		}
+	}
+	if got := MakeMove(g, 3, 1); got != "WIN" {
+		t.Fatalf("fifth in a row: %q, want WIN", got)
+	}
+	if g.Winner != 1 || len(g.WinningLines) != 1 || len(g.WinningLines[0]) != 5 {
+		t.Errorf("winner %d with lines %v", g.Winner, g.WinningLines)
+	}
+
+	// The pre-filled discs count towards a line: player 1's bottom disc
+	// in column 0 and four more make five
+	g = newFiveGame(t)
+	for i, col := range []int{1, 1, 2, 2, 3, 3} {
+		if got := MakeMove(g, col, g.Turn); got != "OK" {
+			t.Fatalf("move %d (column %d): %q", i+1, col, got)
+		}
+	}
+	if got := MakeMove(g, 4, 1); got != "WIN" {
+		t.Fatalf("with the pre-filled disc: %q, want WIN", got)
+	}
+	if line := g.WinningLines[0]; line[0] != (Cell{Row: 5, Column: 0}) {
+		t.Errorf("winning line %v doesn't start at the pre-filled disc", line)
+	}
+}
+
+func TestQueueKeepsVariantsApart(t *testing.T) {
+	resetMatchmaker(t)
+
+	variants := []string{"classic", "popout", "five", "powerup"}
+	for i, v := range variants {
+		name := "waiting-" + v
+		variant, _ := LookupVariant(v)
+		if g := FindMatch(name, DefaultRating, MatchOptions{Variant: v, Rules: variant.Rules()}); g != nil {
+			t.Fatalf("%s paired with %s vs %s", name, g.Player1, g.Player2)
+		}
+		if _, n := QueuePosition(name); n != i+1 {
+			t.Fatalf("%d waiting, want %d", n, i+1)
+		}
+	}
+	// Windows wide open: still nobody is paired across variants
+	sweepQueue(time.Now().Add(time.Hour))
+	if _, n := QueuePosition(""); n != len(variants) {
+		t.Errorf("%d waiting after the sweep, want %d", n, len(variants))
+	}
+
+	// Someone else wanting Power Up gets the Power Up player
+	powerup, _ := LookupVariant("powerup")
+	g := FindMatch("newcomer", DefaultRating, MatchOptions{Variant: "powerup", Rules: powerup.Rules()})
+	if g == nil || g.Player1 != "waiting-powerup" {
+		t.Fatalf("newcomer got %+v, want a game against waiting-powerup", g)
+	}
+	if VariantOf(g).Name() != "powerup" || len(g.Powers[1]) != len(powerDiscs) {
+		t.Errorf("game is %s with powers %v", VariantOf(g).Name(), g.Powers)
+	}
+}
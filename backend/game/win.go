@@ -1,12 +1,9 @@
 package game
 
-func CheckHorizontal(board [6][7]int, player int) bool {
-	for row := 0; row < 6; row++ {
-		for col := 0; col < 4; col++ {
-			if board[row][col] == player &&
-				board[row][col+1] == player &&
-				board[row][col+2] == player &&
-				board[row][col+3] == player {
+func CheckHorizontal(board Board, player int, connect int) bool {
+	for row := 0; row < board.Rows(); row++ {
+		for col := 0; col+connect <= board.Columns(); col++ {
+			if lineOf(board, row, col, 0, 1, connect, player) {
 				return true
 			}
 		}
@@ -14,13 +11,10 @@ func CheckHorizontal(board [6][7]int, player int) bool {
 	return false
 }
 
-func CheckVertical(board [6][7]int, player int) bool {
-	for col := 0; col < 7; col++ {
-		for row := 0; row < 3; row++ {
-			if board[row][col] == player &&
-				board[row+1][col] == player &&
-				board[row+2][col] == player &&
-				board[row+3][col] == player {
+func CheckVertical(board Board, player int, connect int) bool {
+	for col := 0; col < board.Columns(); col++ {
+		for row := 0; row+connect <= board.Rows(); row++ {
+			if lineOf(board, row, col, 1, 0, connect, player) {
 				return true
 			}
 		}
@@ -28,13 +22,10 @@ func CheckVertical(board [6][7]int, player int) bool {
 	return false
 }
 
-func CheckDiagonalRight(board [6][7]int, player int) bool {
-	for row := 0; row < 3; row++ {
-		for col := 0; col < 4; col++ {
-			if board[row][col] == player &&
-				board[row+1][col+1] == player &&
-				board[row+2][col+2] == player &&
-				board[row+3][col+3] == player {
+func CheckDiagonalRight(board Board, player int, connect int) bool {
+	for row := 0; row+connect <= board.Rows(); row++ {
+		for col := 0; col+connect <= board.Columns(); col++ {
+			if lineOf(board, row, col, 1, 1, connect, player) {
 				return true
 			}
 		}
@@ -42,13 +33,10 @@ func CheckDiagonalRight(board [6][7]int, player int) bool {
 	return false
 }
 
-func CheckDiagonalLeft(board [6][7]int, player int) bool {
-	for row := 0; row < 3; row++ {
-		for col := 3; col < 7; col++ {
-			if board[row][col] == player &&
-				board[row+1][col-1] == player &&
-				board[row+2][col-2] == player &&
-				board[row+3][col-3] == player {
+func CheckDiagonalLeft(board Board, player int, connect int) bool {
+	for row := 0; row+connect <= board.Rows(); row++ {
+		for col := connect - 1; col < board.Columns(); col++ {
+			if lineOf(board, row, col, 1, -1, connect, player) {
 				return true
 			}
 		}
@@ -56,9 +44,58 @@ func CheckDiagonalLeft(board [6][7]int, player int) bool {
 	return false
 }
 
-func CheckWin(board [6][7]int, player int) bool {
-	return CheckHorizontal(board, player) ||
-		CheckVertical(board, player) ||
-		CheckDiagonalRight(board, player) ||
-		CheckDiagonalLeft(board, player)
+func CheckWin(board Board, player int, connect int) bool {
+	return CheckHorizontal(board, player, connect) ||
+		CheckVertical(board, player, connect) ||
+		CheckDiagonalRight(board, player, connect) ||
+		CheckDiagonalLeft(board, player, connect)
+}
+
+// lineOf reports whether connect cells starting at (row, col) and stepping
+// by (dr, dc) all belong to player
+func lineOf(board Board, row, col, dr, dc, connect, player int) bool {
+	for i := 0; i < connect; i++ {
+		if board[row+i*dr][col+i*dc] != player {
+			return false
+		}
+	}
+	return true
+}
+
+// Cell is a board square, row 0 being the top
+type Cell struct {
+	Row    int `json:"row"`
+	Column int `json:"column"`
+}
+
+// lineDirections are the (row, column) steps of horizontal, vertical and
+// both diagonal lines
+var lineDirections = [4][2]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}}
+
+// FindWinningLines returns the cells of every line of at least connect of
+// player's discs, each run of discs once however long it is
+func FindWinningLines(board Board, player int, connect int) [][]Cell {
+	var lines [][]Cell
+	inside := func(row, col int) bool {
+		return row >= 0 && row < board.Rows() && col >= 0 && col < board.Columns()
+	}
+	for _, d := range lineDirections {
+		dr, dc := d[0], d[1]
+		for row := 0; row < board.Rows(); row++ {
+			for col := 0; col < board.Columns(); col++ {
+				// only start at the first disc of a run
+				if board[row][col] != player || (inside(row-dr, col-dc) && board[row-dr][col-dc] == player) {
+					continue
+				}
+				var run []Cell
+				for r, c := row, col; inside(r, c) && board[r][c] == player; r, c = r+dr, c+dc {
+					run = append(run, Cell{Row: r, Column: c})
+				}
+				if len(run) >= connect {
+					lines = append(lines, run)
+				}
+			}
+		}
+	}
+	return lines
 }
@@ -0,0 +1,185 @@
+package game
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// cells lists (row, column) pairs as Cells
+func cells(rc ...int) []Cell {
+	var line []Cell
+	for i := 0; i+1 < len(rc); i += 2 {
+		line = append(line, Cell{Row: rc[i], Column: rc[i+1]})
+	}
+	return line
+}
+
+func TestFindWinningLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		rows   [6]string
+		player int
+		want   [][]Cell
+	}{
+		{
+			name:   "none",
+			rows:   [6]string{".......", ".......", ".......", ".......", "OOO....", "XXX.X.."},
+			player: 1,
+		},
+		{
+			name:   "horizontal",
+			rows:   [6]string{".......", ".......", ".......", ".......", "OOO....", ".XXXX.."},
+			player: 1,
+			want:   [][]Cell{cells(5, 1, 5, 2, 5, 3, 5, 4)},
+		},
+		{
+			name:   "vertical",
+			rows:   [6]string{".......", ".......", "......O", "......O", "X.....O", "XX....O"},
+			player: 2,
+			want:   [][]Cell{cells(2, 6, 3, 6, 4, 6, 5, 6)},
+		},
+		{
+			name:   "falling diagonal",
+			rows:   [6]string{".......", ".......", "X......", "OX.....", "OOX....", "OXOX..."},
+			player: 1,
+			want:   [][]Cell{cells(2, 0, 3, 1, 4, 2, 5, 3)},
+		},
+		{
+			name:   "rising diagonal",
+			rows:   [6]string{".......", ".......", "...X...", "..XO...", ".XOO...", "XOOX..."},
+			player: 1,
+			want:   [][]Cell{cells(2, 3, 3, 2, 4, 1, 5, 0)},
+		},
+		{
+			name:   "a run of five is one line",
+			rows:   [6]string{".......", ".......", ".......", ".......", ".OOOO..", "XXXXX.."},
+			player: 1,
+			want:   [][]Cell{cells(5, 0, 5, 1, 5, 2, 5, 3, 5, 4)},
+		},
+		{
+			name:   "separate runs",
+			rows:   [6]string{".......", ".......", "X......", "X......", "XOOO...", "XXXXOOO"},
+			player: 1,
+			want:   [][]Cell{cells(5, 0, 5, 1, 5, 2, 5, 3), cells(2, 0, 3, 0, 4, 0, 5, 0)},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			board := boardFromRows(t, tc.rows)
+			if got := FindWinningLines(board, tc.player, 4); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+			if got := CheckWin(board, tc.player, 4); got != (len(tc.want) > 0) {
+				t.Errorf("CheckWin = %v", got)
+			}
+		})
+	}
+}
+
+func TestMoveCompletesTwoLines(t *testing.T) {
+	// Column 3 finishes the bottom row and the diagonal down from the right
+	g := NewGame("alice", "bob")
+	g.Board = boardFromRows(t, [6]string{
+		".......",
+		".......",
+		"......X",
+		".....XO",
+		"....XOO",
+		"XXX.OOO",
+	})
+	if got := MakeMove(g, 3, 1); got != "WIN" {
+		t.Fatalf("MakeMove = %q, want WIN", got)
+	}
+	want := [][]Cell{
+		cells(5, 0, 5, 1, 5, 2, 5, 3),
+		cells(2, 6, 3, 5, 4, 4, 5, 3),
+	}
+	if !reflect.DeepEqual(g.WinningLines, want) {
+		t.Errorf("winning lines %v, want %v", g.WinningLines, want)
+	}
+}
+
+func TestFindWinningLinesConnectFive(t *testing.T) {
+	tests := []struct {
+		board   string
+		connect int
+		lines   []int // lengths of the lines found
+	}{
+		{"9/9/9/9/9/xxxx5 o", 5, nil},
+		{"9/9/9/9/9/xxxx5 o", 4, []int{4}},
+		{"9/9/9/9/9/xxxxx4 o", 5, []int{5}},
+		{"9/9/9/9/9/xxxxxx3 o", 5, []int{6}},
+		{"9/9/9/9/9/xxxxxoxxx o", 5, []int{5}},
+		{"9/x8/x8/x8/x8/x8 o", 5, []int{5}},
+		{"9/4x4/3xo4/2xoo4/1xooo4/xoooo4 o", 5, []int{5}},
+	}
+	for _, tc := range tests {
+		board, _, err := ParseBoard(tc.board)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.board, err)
+		}
+		var lengths []int
+		for _, line := range FindWinningLines(board, 1, tc.connect) {
+			lengths = append(lengths, len(line))
+		}
+		if !reflect.DeepEqual(lengths, tc.lines) {
+			t.Errorf("%s with connect %d: lines of %v, want %v", tc.board, tc.connect, lengths, tc.lines)
+		}
+	}
+}
+
+func TestPopOutWinningLines(t *testing.T) {
+	// The pop drops a line into place for each player; the popper wins
+	// and only their line is shown
+	for _, player := range []int{1, 2} {
+		rows := [6]string{".......", ".......", ".......", "...X...", "...OXXX", "OOOXOXO"}
+		if player == 2 {
+			rows = [6]string{".......", ".......", ".......", "...O...", "X..XOOO", "XXXOXOX"}
+		}
+		g := newPopOutGame(t, rows, player)
+		if got := MakePop(g, 3, player); got != "WIN" {
+			t.Fatalf("player %d: MakePop = %q, want WIN", player, got)
+		}
+		mine := FindWinningLines(g.Board, player, 4)
+		theirs := FindWinningLines(g.Board, opponent(player), 4)
+		if len(mine) != 1 || len(theirs) != 1 {
+			t.Fatalf("player %d: %d lines of their own and %d of the opponent's, want 1 each", player, len(mine), len(theirs))
+		}
+		if g.Winner != player || !reflect.DeepEqual(g.WinningLines, mine) {
+			t.Errorf("player %d: winner %d with lines %v, want lines %v", player, g.Winner, g.WinningLines, mine)
+		}
+	}
+}
+
+func TestFindWinningLinesMatchesWinningCells(t *testing.T) {
+	// A disc on one of the bitboard's winning cells makes a line through
+	// it, and a disc anywhere else makes none
+	rng := rand.New(rand.NewSource(3))
+	for _, rules := range []Rules{ClassicRules, rules7x8, rules6x9} {
+		g := geometryFor(rules)
+		for i := 0; i < 100; i++ {
+			p := randomPosition(rules, rng.Intn(rules.Rows*rules.Columns), rng)
+			board := p.Board()
+			for player := 1; player <= 2; player++ {
+				win := g.winningCells(p.discs[player-1], p.mask())
+				for col := 0; col < rules.Columns; col++ {
+					for h := p.heights[col]; h < rules.Rows; h++ {
+						cell := Cell{Row: rules.Rows - 1 - h, Column: col}
+						board[cell.Row][cell.Column] = player
+						found := false
+						for _, line := range FindWinningLines(board, player, rules.Connect) {
+							for _, c := range line {
+								found = found || c == cell
+							}
+						}
+						board[cell.Row][cell.Column] = 0
+						if want := win&g.cellBit(col, h) != 0; found != want {
+							t.Fatalf("%s, %s: player %d at %v makes a line %v, want %v", rules, p, player, cell, found, want)
+						}
+					}
+				}
+			}
+		}
+	}
+}
@@ -5,13 +5,17 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 
 	"connect4/db"
+	"connect4/game"
 	"connect4/websocket"
 )
 
 func main() {
 	db.ConnectMongo()
+	registerEngines(os.Getenv("ENGINE_BOTS"))
 
 	http.HandleFunc("/ws", websocket.HandleWS)
 	http.HandleFunc("/leaderboard", withCORS(leaderboardHandler))
@@ -26,10 +30,39 @@ func main() {
 	log.Fatal(http.ListenAndServe(":"+port, nil))
 }
 
+// registerEngines registers external engine bots from a comma-separated
+// list of "name=command args" specs, e.g. ENGINE_BOTS="rusty=./rusty-c4 --hash 64"
+func registerEngines(specs string) {
+	for _, spec := range strings.Split(specs, ",") {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+		engine, err := game.ParseEngineSpec(spec)
+		if err != nil {
+			log.Printf("⚠️  WARNING: %v", err)
+			continue
+		}
+		game.RegisterBot(engine)
+		log.Printf("Registered engine bot %q", engine.Name())
+	}
+}
+
+// leaderboardHandler lists players by rating. ?minGames=N leaves out
+// players with fewer rated games (db.DefaultMinGames by default).
 func leaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	minGames := db.DefaultMinGames
+	if s := r.URL.Query().Get("minGames"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			http.Error(w, "minGames must be a non-negative number", http.StatusBadRequest)
+			return
+		}
+		minGames = n
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 
-	data, err := db.GetLeaderboard()
+	data, err := db.GetLeaderboard(minGames)
 	if err != nil {
 		http.Error(w, "Failed to fetch leaderboard", http.StatusInternalServerError)
 		return
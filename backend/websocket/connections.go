@@ -1,29 +1,54 @@
 package websocket
 
 import (
+	"sync"
+
 	"github.com/gorilla/websocket"
 )
 
+// safeConn is a WebSocket connection that several goroutines may write to.
+// Gorilla connections allow only one concurrent writer, so writes take
+// writeLock.
+type safeConn struct {
+	*websocket.Conn
+	writeLock sync.Mutex
+}
+
+// WriteMessage sends one message, waiting for any other writer
+func (c *safeConn) WriteMessage(messageType int, data []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	return c.Conn.WriteMessage(messageType, data)
+}
+
 // ConnectionManager manages WebSocket connections for games
 type ConnectionManager struct {
-	connections map[string]map[string]*websocket.Conn // gameID -> username -> conn
+	mu          sync.RWMutex
+	connections map[string]map[string]*safeConn // gameID -> username -> conn
+	spectators  map[string]map[*safeConn]bool   // gameID -> read-only conns
 }
 
 var manager = &ConnectionManager{
-	connections: make(map[string]map[string]*websocket.Conn),
+	connections: make(map[string]map[string]*safeConn),
+	spectators:  make(map[string]map[*safeConn]bool),
 }
 
 // AddConnection adds a connection for a game
-func (cm *ConnectionManager) AddConnection(gameID, username string, conn *websocket.Conn) {
+func (cm *ConnectionManager) AddConnection(gameID, username string, conn *safeConn) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
 	if cm.connections[gameID] == nil {
-		cm.connections[gameID] = make(map[string]*websocket.Conn)
+		cm.connections[gameID] = make(map[string]*safeConn)
 	}
 	cm.connections[gameID][username] = conn
 }
 
-// RemoveConnection removes a connection
-func (cm *ConnectionManager) RemoveConnection(gameID, username string) {
-	if cm.connections[gameID] != nil {
+// RemoveConnection removes a connection, unless the player has already
+// reconnected with a new one
+func (cm *ConnectionManager) RemoveConnection(gameID, username string, conn *safeConn) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if cm.connections[gameID] != nil && cm.connections[gameID][username] == conn {
 		delete(cm.connections[gameID], username)
 		if len(cm.connections[gameID]) == 0 {
 			delete(cm.connections, gameID)
@@ -31,28 +56,87 @@ func (cm *ConnectionManager) RemoveConnection(gameID, username string) {
 	}
 }
 
-// BroadcastToGame sends a message to all players in a game
-func (cm *ConnectionManager) BroadcastToGame(gameID string, message []byte) {
-	if cm.connections[gameID] == nil {
-		return
+// Connection returns username's connection to a game, or nil
+func (cm *ConnectionManager) Connection(gameID, username string) *safeConn {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.connections[gameID][username]
+}
+
+// AddSpectator adds a read-only connection to a game
+func (cm *ConnectionManager) AddSpectator(gameID string, conn *safeConn) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if cm.spectators[gameID] == nil {
+		cm.spectators[gameID] = make(map[*safeConn]bool)
 	}
-	
+	cm.spectators[gameID][conn] = true
+}
+
+// RemoveSpectator removes a spectator's connection from every game it
+// watches
+func (cm *ConnectionManager) RemoveSpectator(conn *safeConn) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	for gameID, conns := range cm.spectators {
+		delete(conns, conn)
+		if len(conns) == 0 {
+			delete(cm.spectators, gameID)
+		}
+	}
+}
+
+// CopySpectators lets everyone watching one game watch another too, e.g.
+// its rematch
+func (cm *ConnectionManager) CopySpectators(fromGameID, toGameID string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	for conn := range cm.spectators[fromGameID] {
+		if cm.spectators[toGameID] == nil {
+			cm.spectators[toGameID] = make(map[*safeConn]bool)
+		}
+		cm.spectators[toGameID][conn] = true
+	}
+}
+
+// SpectatorCount returns how many connections are watching a game
+func (cm *ConnectionManager) SpectatorCount(gameID string) int {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return len(cm.spectators[gameID])
+}
+
+// BroadcastToGame sends a message to all players and spectators in a game
+func (cm *ConnectionManager) BroadcastToGame(gameID string, message []byte) {
+	cm.mu.RLock()
+	conns := make(map[string]*safeConn, len(cm.connections[gameID]))
 	for username, conn := range cm.connections[gameID] {
+		conns[username] = conn
+	}
+	watchers := make([]*safeConn, 0, len(cm.spectators[gameID]))
+	for conn := range cm.spectators[gameID] {
+		watchers = append(watchers, conn)
+	}
+	cm.mu.RUnlock()
+
+	for username, conn := range conns {
 		if conn != nil {
 			if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
 				// Connection might be closed, remove it
-				delete(cm.connections[gameID], username)
+				cm.RemoveConnection(gameID, username, conn)
 			}
 		}
 	}
+	for _, conn := range watchers {
+		if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			cm.RemoveSpectator(conn)
+		}
+	}
 }
 
 // SendToPlayer sends a message to a specific player
 func (cm *ConnectionManager) SendToPlayer(gameID, username string, message []byte) {
-	if cm.connections[gameID] != nil {
-		if conn := cm.connections[gameID][username]; conn != nil {
-			conn.WriteMessage(websocket.TextMessage, message)
-		}
+	if conn := cm.Connection(gameID, username); conn != nil {
+		conn.WriteMessage(websocket.TextMessage, message)
 	}
 }
-
@@ -2,8 +2,12 @@ package websocket
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"connect4/db"
@@ -17,11 +21,12 @@ var upgrader = websocket.Upgrader{
 }
 
 func HandleWS(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println("Upgrade error:", err)
 		return
 	}
+	conn := &safeConn{Conn: ws}
 	defer conn.Close()
 
 	// ✅ username and gameID from query (for reconnection)
@@ -32,16 +37,83 @@ func HandleWS(w http.ResponseWriter, r *http.Request) {
 		username = "player1"
 	}
 
+	// 👀 SPECTATORS: watch a live game read-only
+	if r.URL.Query().Get("spectate") == "true" {
+		spectate(conn, gameID)
+		return
+	}
+
+	// ✅ Bot difficulty (only used if the bot ends up joining). Without
+	// one, the bot is picked to match the player's rating.
+	// ?bot=<name> picks any registered bot directly, e.g. for A/B tests.
+	difficulty, ok := game.ParseDifficulty(r.URL.Query().Get("difficulty"))
+	if !ok {
+		sendError(conn, "Unknown difficulty")
+		return
+	}
+	botName := difficulty.BotName()
+	if r.URL.Query().Get("difficulty") == "" {
+		botName = ""
+	}
+	if name := r.URL.Query().Get("bot"); name != "" {
+		if _, ok := game.LookupBot(name); !ok {
+			sendError(conn, "Unknown bot")
+			return
+		}
+		botName = name
+	}
+	seat, ok := game.ParseSeat(r.URL.Query().Get("seat"))
+	if !ok {
+		sendError(conn, "Unknown seat")
+		return
+	}
+	// ✅ Practice mode: the bot grants every takeback
+	practice := r.URL.Query().Get("practice") == "true"
+
+	// ✅ Variant, board size and win length (classic 6x7 connect 4 by default)
+	variant, ok := game.LookupVariant(r.URL.Query().Get("variant"))
+	if !ok {
+		sendError(conn, "Unknown variant")
+		return
+	}
+	rules, err := parseRules(r.URL.Query(), variant.Rules())
+	if err != nil {
+		sendError(conn, "Invalid rules: "+err.Error())
+		return
+	}
+	// ✅ Time control, e.g. time=3+2 or time=30s (untimed by default).
+	// An unescaped '+' in a query string decodes to a space.
+	timeControl, err := game.ParseTimeControl(strings.ReplaceAll(r.URL.Query().Get("time"), " ", "+"))
+	if err != nil {
+		sendError(conn, "Invalid time control: "+err.Error())
+		return
+	}
+	match := game.MatchOptions{Variant: variant.Name(), Rules: rules, TimeControl: timeControl}
+
+	// ✅ Private rooms: room=new creates one, room=<code> joins a friend's
+	room := strings.ToUpper(r.URL.Query().Get("room"))
+
+	// ✅ Rating for skill-based matchmaking
+	rating := game.DefaultRating
+	if rt, err := db.GetRating(username); err != nil {
+		log.Printf("Rating lookup failed for %s: %v", username, err)
+	} else {
+		rating = rt.Rating
+	}
+
 	log.Printf("Player connected: %s (gameID: %s)", username, gameID)
 
 	var g *game.Game
+	var roomCode string // Private room this player is hosting, if any
 
 	// ✅ RECONNECTION: Try to find existing game
 	if gameID != "" {
 		g = game.FindGameByID(gameID)
 		if g != nil {
+			g.Lock()
 			// Verify username matches
 			if username != g.Player1 && username != g.Player2 {
+				g.Unlock()
 				sendError(conn, "Username doesn't match this game")
 				return
 			}
@@ -64,6 +136,7 @@ func HandleWS(w http.ResponseWriter, r *http.Request) {
 				"opponent": opponentName,
 			})
 			sendState(conn, g)
+			g.Unlock()
 			// Continue to game loop below
 		}
 	}
@@ -72,49 +145,124 @@ func HandleWS(w http.ResponseWriter, r *http.Request) {
 	if g == nil {
 		g = game.FindGameByUsername(username)
 		// Only reuse game if it's not finished (for reconnection to active game)
-		if g != nil && g.GameOver {
-			// Game is finished, remove it and create a new one
-			game.RemoveGame(g.ID)
-			g = nil
+		if g != nil {
+			g.Lock()
+			over := g.GameOver
+			g.Unlock()
+			if over {
+				// Game is finished, remove it and create a new one
+				game.RemoveGame(g.ID)
+				g = nil
+			}
 		}
 		if g == nil {
-			g = game.FindMatch(username)
+			switch room {
+			case "":
+				g = game.FindMatch(username, rating, match)
+			case "NEW":
+				roomCode = game.CreateRoom(username, match)
+			default:
+				if g, err = game.JoinRoom(room, username); err != nil {
+					sendError(conn, err.Error())
+					return
+				}
+			}
 		}
 	}
 
+	// Read in the background from here on, so a socket closing while the
+	// player waits in the queue is noticed
+	done := make(chan struct{})
+	defer close(done)
+	msgs := readMessages(conn, done)
+
 	if g == nil {
-		// Send waiting message to client
-		sendMessage(conn, "waiting", map[string]interface{}{
-			"message": "Waiting for opponent... Bot will join in 10 seconds if no player found.",
-		})
+		position, waiting := 0, 0
+		if roomCode != "" {
+			// 🔑 Share the code with the friend; the bot never joins
+			sendMessage(conn, "room_created", map[string]interface{}{
+				"message":   "Private room created. Share the code with a friend to play.",
+				"code":      roomCode,
+				"expiresIn": int(game.RoomTTL.Seconds()),
+			})
+		} else {
+			// Send waiting message to client, with their place in the queue
+			position, waiting = game.QueuePosition(username)
+			sendWaiting(conn, position, waiting)
 
-		// Start bot timer
-		game.StartBotIfNoPlayer(username)
+			// Start bot timer
+			game.StartBotIfNoPlayer(username, game.BotOptions{
+				Difficulty: difficulty,
+				BotName:    botName,
+				Seat:       seat,
+				Practice:   practice,
+			})
+		}
 
 		// Wait until game is assigned (either match found or bot joined)
-		for {
-			time.Sleep(500 * time.Millisecond)
-			g = game.ActiveGames[username]
-			if g != nil {
-				// Send game started message
-				if g.Player2 == "BOT" {
-					sendMessage(conn, "game_started", map[string]interface{}{
-						"message":  "Bot joined! Game starting...",
-						"opponent": "BOT",
-					})
-				} else {
-					sendMessage(conn, "game_started", map[string]interface{}{
-						"message":  "Match found! Game starting...",
-						"opponent": g.Player2,
-					})
+		ticker := time.NewTicker(500 * time.Millisecond)
+		for g == nil {
+			select {
+			case in := <-msgs:
+				if in.err != nil {
+					// 🚪 Socket closed while queued (or hosting)
+					ticker.Stop()
+					leaveQueue(username, roomCode)
+					return
+				}
+				// Nothing to do until the game starts
+			case <-ticker.C:
+				if g = game.FindGameByUsername(username); g != nil {
+					continue
+				}
+				if roomCode != "" {
+					if !game.RoomOpen(roomCode) {
+						ticker.Stop()
+						sendError(conn, "Room expired - nobody joined")
+						return
+					}
+					continue
+				}
+				// Let the player know when they move up the queue
+				if p, n := game.QueuePosition(username); p != 0 && (p != position || n != waiting) {
+					position, waiting = p, n
+					sendWaiting(conn, position, waiting)
 				}
-				// Send initial game state
-				sendState(conn, g)
-				break
 			}
 		}
+		ticker.Stop()
+
+		g.Lock()
+		opponentName := g.Player2
+		if username == g.Player2 {
+			opponentName = g.Player1
+		}
+		// Send game started message
+		if g.BotName != "" {
+			sendMessage(conn, "game_started", map[string]interface{}{
+				"message":    "Bot joined! Game starting...",
+				"opponent":   opponentName,
+				"difficulty": g.Difficulty,
+				"bot":        g.BotName,
+				"botFirst":   g.BotPlayer == 1,
+				"practice":   g.Practice,
+			})
+		} else {
+			message := "Match found! Game starting..."
+			if roomCode != "" {
+				message = "Friend joined! Game starting..."
+			}
+			sendMessage(conn, "game_started", map[string]interface{}{
+				"message":  message,
+				"opponent": opponentName,
+			})
+		}
+		// Send initial game state
+		sendState(conn, g)
+		g.Unlock()
 	} else {
 		// Match found immediately - determine opponent
+		g.Lock()
 		opponentName := g.Player2
 		if username == g.Player2 {
 			opponentName = g.Player1
@@ -124,24 +272,40 @@ func HandleWS(w http.ResponseWriter, r *http.Request) {
 			"opponent": opponentName,
 		})
 		sendState(conn, g)
+		g.Unlock()
 	}
 
 	// Mark connection as active and add to connection manager
+	g.Lock()
 	if g.Connections == nil {
 		g.Connections = make(map[string]bool)
 	}
 	g.Connections[username] = true
+	g.Unlock()
 	manager.AddConnection(g.ID, username, conn)
 
-	// Start disconnect monitoring goroutine
-	go monitorDisconnection(g, username)
+	// Start disconnect (and clock) monitoring goroutines
+	startMonitors(g, username)
+
+	// 🤖 BOT OPENING MOVE (bot took Player1 and moves first)
+	go playBotTurn(g)
 
 	for {
-		_, msg, err := conn.ReadMessage()
+		in := <-msgs
+		msg, err := in.msg, in.err
+
+		// ♻️ Follow any rematch the opponent accepted meanwhile
+		g = game.Latest(g)
+
 		if err != nil {
 			log.Printf("Read error for %s: %v", username, err)
-			handleDisconnect(g, username)
-			manager.RemoveConnection(g.ID, username)
+			g.Lock()
+			// A player who already reconnected is still here
+			if manager.Connection(g.ID, username) == conn {
+				handleDisconnect(g, username)
+			}
+			g.Unlock()
+			manager.RemoveConnection(g.ID, username, conn)
 			return
 		}
 
@@ -150,93 +314,524 @@ func HandleWS(w http.ResponseWriter, r *http.Request) {
 			sendError(conn, "Invalid JSON")
 			continue
 		}
+		msgType, _ := data["type"].(string)
 
-		if data["type"] == "move" {
+		var ended *gameResult // saved once the game is unlocked
+		g.Lock()
+		switch msgType {
+		// "pop" removes your own bottom disc in PopOut games
+		case "move", "pop":
+			ended = handleMove(conn, g, username, msgType, data)
 
-			colFloat, ok := data["column"].(float64)
-			if !ok {
-				sendError(conn, "Invalid column")
-				continue
-			}
+		// ↩️ TAKEBACKS: ask the opponent to undo your last move
+		case "takeback_request", "takeback_accept", "takeback_decline":
+			handleTakeback(conn, g, username, msgType)
 
-			col := int(colFloat)
+		// 🏳️ RESIGN or DRAW OFFERS
+		case "resign", "offer_draw", "accept_draw", "decline_draw":
+			ended = handleEnding(conn, g, username, msgType)
 
-			// ✅ PLAYER MOVE - Check if it's player's turn
-			playerNum := 1
-			if username == g.Player2 {
-				playerNum = 2
+		// 🔁 REMATCH: same players, colours swapped
+		case "rematch", "rematch_accept", "rematch_decline":
+			if next := handleRematch(conn, g, username, msgType); next != nil {
+				g.Unlock()
+				g = next
+				g.Lock()
+				announceRematch(g)
 			}
+		}
+		g.Unlock()
+		if ended != nil {
+			ended.save()
+		}
 
-			// Make the move using function version
-			result := game.MakeMove(g, col, playerNum)
-			if result != "OK" && result != "WIN" && result != "DRAW" {
-				sendError(conn, result)
-				continue
-			}
+		// 🤖 BOT MOVE (if bot is opponent and it's bot's turn)
+		go playBotTurn(g)
+	}
+}
+
+// ---------------- helpers ----------------
+
+// Unless noted otherwise, helpers that take a game expect the caller to
+// hold its lock.
 
-			// Broadcast state to all players in the game
-			broadcastState(g)
+// incoming is one message read from a socket, or the error that ended it
+type incoming struct {
+	msg []byte
+	err error
+}
 
-			// ✅ PLAYER WIN or DRAW
-			if result == "WIN" || result == "DRAW" {
-				saveAndEndGame(g)
-				// Give a moment for the game_over message to be sent before closing
-				time.Sleep(100 * time.Millisecond)
+// readMessages reads conn in the background until a read fails or done is
+// closed, handing each message (and the final error) to the handler
+func readMessages(conn *safeConn, done <-chan struct{}) <-chan incoming {
+	msgs := make(chan incoming)
+	go func() {
+		for {
+			_, msg, err := conn.ReadMessage()
+			select {
+			case msgs <- incoming{msg: msg, err: err}:
+			case <-done:
+				return
+			}
+			if err != nil {
 				return
 			}
+		}
+	}()
+	return msgs
+}
 
-			// 🤖 BOT MOVE (if bot is opponent and it's bot's turn)
-			if g.Player2 == "BOT" && !g.GameOver && g.Turn == 2 {
-				time.Sleep(700 * time.Millisecond) // feels human 😄
+// sendWaiting tells a queued player their place in the queue (1 is next
+// in line) and how many players are waiting
+func sendWaiting(conn *safeConn, position, waiting int) {
+	sendMessage(conn, "waiting", map[string]interface{}{
+		"message":  "Waiting for opponent... Bot will join in 10 seconds if no player found.",
+		"position": position,
+		"queued":   waiting,
+	})
+}
 
-				botCol := game.BotMove(g)
-				botResult := game.MakeMove(g, botCol, 2)
+// leaveQueue takes a player whose socket closed out of the queue, or closes
+// the private room they host. If they were matched at that very moment,
+// their new game treats them as disconnected instead, so the opponent isn't
+// left waiting.
+func leaveQueue(username, roomCode string) {
+	if roomCode != "" && game.CloseRoom(roomCode) {
+		log.Printf("Player %s closed room %s", username, roomCode)
+		return
+	}
+	if roomCode == "" && game.CancelMatch(username) {
+		log.Printf("Player %s left the queue", username)
+		return
+	}
+	if g := game.FindGameByUsername(username); g != nil {
+		g.Lock()
+		handleDisconnect(g, username)
+		g.Unlock()
+		go monitorDisconnection(g, username)
+	}
+}
 
-				// Broadcast state to player
-				broadcastState(g)
+// spectate streams game gameID to conn read-only until the socket closes,
+// following the game into any rematches
+func spectate(conn *safeConn, gameID string) {
+	g := game.FindGameByID(gameID)
+	if g == nil {
+		sendError(conn, "Game not found")
+		return
+	}
 
-				// Check if bot won or draw
-				if botResult == "WIN" || botResult == "DRAW" {
-					saveAndEndGame(g)
-					// Give a moment for the game_over message to be sent before closing
-					time.Sleep(100 * time.Millisecond)
-					return
-				}
-			}
+	g.Lock()
+	manager.AddSpectator(g.ID, conn)
+	log.Printf("Spectator joined game %s (%d watching)", g.ID, manager.SpectatorCount(g.ID))
+	sendMessage(conn, "spectating", map[string]interface{}{
+		"message": "Watching game",
+		"gameId":  g.ID,
+		"player1": g.Player1,
+		"player2": g.Player2,
+	})
+	// The new spectator gets the full state, everyone the new count
+	broadcastState(g)
+	g.Unlock()
+
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
 		}
+		sendError(conn, "Spectators can't make moves")
 	}
+
+	manager.RemoveSpectator(conn)
+	g = game.Latest(g)
+	g.Lock()
+	broadcastState(g)
+	g.Unlock()
 }
 
-// ---------------- helpers ----------------
+// startMonitors starts the goroutines that watch username's connection to
+// g and, in timed games, the clock. g must not be locked.
+func startMonitors(g *game.Game, username string) {
+	go monitorDisconnection(g, username)
+
+	// ⏱️ Flag the player to move when their clock runs out
+	g.Lock()
+	timed := g.TimeControl.Timed()
+	g.Unlock()
+	if timed {
+		go monitorClock(g)
+	}
+}
+
+// handleMove plays a move (or pop) from username and ends the game if it
+// was decisive, returning the result to save
+func handleMove(conn *safeConn, g *game.Game, username, msgType string, data map[string]interface{}) *gameResult {
+	colFloat, ok := data["column"].(float64)
+	if !ok {
+		sendError(conn, "Invalid column")
+		return nil
+	}
+
+	move := game.Move{Column: int(colFloat), Pop: msgType == "pop"}
+	if power, ok := data["power"].(string); ok {
+		move.Power = game.PowerDisc(power) // Power Up special disc
+	}
+
+	// ✅ PLAYER MOVE - Check if it's player's turn
+	playerNum := 1
+	if username == g.Player2 {
+		playerNum = 2
+	}
+
+	// Make the move using function version
+	result := game.PlayMove(g, move, playerNum)
+	if result != "OK" && result != "WIN" && result != "DRAW" {
+		sendError(conn, result)
+		return nil
+	}
+
+	// Broadcast state to all players in the game
+	broadcastState(g)
+
+	// ✅ PLAYER WIN or DRAW
+	if result == "WIN" || result == "DRAW" {
+		return endGame(g)
+	}
+	return nil
+}
 
-func saveAndEndGame(g *game.Game) {
-	err := db.SaveGameResult(g.Player1, g.Player2, g.Winner)
+// playBotTurn makes the bot's moves while it is the bot's turn (some
+// variants give extra moves) and broadcasts each new state. g must not be
+// locked, and it stays unlocked while the bot pauses and thinks, so the
+// player can still resign, the clock can flag and disconnects are handled
+// meanwhile. Run it in its own goroutine: only one call per game plays,
+// any others return at once.
+func playBotTurn(g *game.Game) {
+	g.Lock()
+	if g.BotBusy {
+		g.Unlock()
+		return
+	}
+	g.BotBusy = true
+	for {
+		// g is locked here
+		if g.BotName == "" || g.GameOver || g.Turn != g.BotPlayer {
+			g.BotBusy = false
+			g.Unlock()
+			return
+		}
+		pause := game.BotPauseTime(g)
+		g.Unlock()
+
+		time.Sleep(pause) // feels human 😄
+
+		g.Lock()
+		if g.GameOver || g.Turn != g.BotPlayer {
+			// the clock or a disconnect ended the game in the meantime
+			continue
+		}
+		botName, p, played, think := g.BotName, game.GamePosition(g), len(g.History), game.BotThinkTime(g)
+		g.Unlock()
+
+		// Think on a snapshot of the position
+		botMove := game.ThinkBotMove(botName, p, think)
+
+		g.Lock()
+		if g.GameOver || g.Turn != g.BotPlayer || len(g.History) != played || game.GamePosition(g) != p {
+			// the game ended or moved on (a takeback) while the bot thought
+			continue
+		}
+		botMove = game.LegalBotMove(g, botMove)
+		botResult := game.PlayMove(g, botMove, g.BotPlayer)
+		if botResult != "OK" && botResult != "WIN" && botResult != "DRAW" {
+			log.Printf("Bot move %+v rejected in game %s: %s", botMove, g.ID, botResult)
+			g.BotBusy = false
+			g.Unlock()
+			return
+		}
+
+		// Broadcast state to player
+		broadcastState(g)
+
+		// Check if bot won or draw
+		if botResult == "WIN" || botResult == "DRAW" {
+			ended := endGame(g)
+			g.Unlock()
+			ended.save()
+			g.Lock()
+		}
+	}
+}
+
+// handleTakeback runs one step of the takeback flow for username. The
+// bot answers at once: yes in practice mode, otherwise takebacks are off.
+func handleTakeback(conn *safeConn, g *game.Game, username, msgType string) {
+	playerNum := 1
+	if username == g.Player2 {
+		playerNum = 2
+	}
+
+	if g.BotName != "" {
+		if msgType != "takeback_request" {
+			sendError(conn, "No takeback to answer")
+			return
+		}
+		if !g.Practice {
+			sendError(conn, "Takebacks against the bot are only allowed in practice mode")
+			return
+		}
+		if err := game.Takeback(g, playerNum); err != nil {
+			sendError(conn, err.Error())
+			return
+		}
+		sendMessage(conn, "takeback_accepted", map[string]interface{}{
+			"player": g.BotName,
+		})
+		broadcastState(g)
+		return
+	}
+
+	var err error
+	var reply string
+	switch msgType {
+	case "takeback_request":
+		err, reply = game.RequestTakeback(g, playerNum), "takeback_requested"
+	case "takeback_accept":
+		err, reply = game.AcceptTakeback(g, playerNum), "takeback_accepted"
+	case "takeback_decline":
+		err, reply = game.DeclineTakeback(g, playerNum), "takeback_declined"
+	}
+	if err != nil {
+		sendError(conn, err.Error())
+		return
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":   reply,
+		"player": username,
+	})
+	manager.BroadcastToGame(g.ID, data)
+	broadcastState(g) // the board after an accept, the pending request otherwise
+}
+
+// handleEnding handles a resignation or a step of a draw offer from
+// username, returning the result to save if the game ended. The bot always
+// plays on.
+func handleEnding(conn *safeConn, g *game.Game, username, msgType string) *gameResult {
+	playerNum := 1
+	if username == g.Player2 {
+		playerNum = 2
+	}
+
+	if g.BotName != "" && msgType == "offer_draw" {
+		if g.GameOver {
+			sendError(conn, "Game already finished")
+			return nil
+		}
+		sendMessage(conn, "draw_declined", map[string]interface{}{
+			"player": g.BotName,
+		})
+		return nil
+	}
+
+	var err error
+	var reply string
+	switch msgType {
+	case "resign":
+		err = game.Resign(g, playerNum)
+	case "offer_draw":
+		err, reply = game.OfferDraw(g, playerNum), "draw_offered"
+	case "accept_draw":
+		err = game.AcceptDraw(g, playerNum)
+	case "decline_draw":
+		err, reply = game.DeclineDraw(g, playerNum), "draw_declined"
+	}
+	if err != nil {
+		sendError(conn, err.Error())
+		return nil
+	}
+
+	if g.GameOver {
+		log.Printf("Game %s ended by %s (%s)", g.ID, g.EndReason, username)
+		broadcastState(g)
+		return endGame(g)
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":   reply,
+		"player": username,
+	})
+	manager.BroadcastToGame(g.ID, data)
+	broadcastState(g)
+	return nil
+}
+
+// handleRematch runs one step of the rematch flow for username and returns
+// the new game once a rematch is agreed. The bot always agrees.
+func handleRematch(conn *safeConn, g *game.Game, username, msgType string) *game.Game {
+	playerNum := 1
+	opponentName := g.Player2
+	if username == g.Player2 {
+		playerNum = 2
+		opponentName = g.Player1
+	}
+
+	var next *game.Game
+	var err error
+	var reply string
+	switch {
+	case g.BotName != "" && msgType == "rematch":
+		if err = game.RequestRematch(g, playerNum); err == nil {
+			next = game.Rematch(g)
+		}
+	case g.BotName != "":
+		err = fmt.Errorf("No rematch to answer")
+	case msgType == "rematch":
+		if !g.Connections[opponentName] {
+			err = fmt.Errorf("%s has left", opponentName)
+		} else {
+			err, reply = game.RequestRematch(g, playerNum), "rematch_requested"
+		}
+	case msgType == "rematch_accept":
+		next, err = game.AcceptRematch(g, playerNum)
+	case msgType == "rematch_decline":
+		err, reply = game.DeclineRematch(g, playerNum), "rematch_declined"
+	}
+	if err != nil {
+		sendError(conn, err.Error())
+		return nil
+	}
+
+	if next == nil {
+		data, _ := json.Marshal(map[string]interface{}{
+			"type":   reply,
+			"player": username,
+		})
+		manager.BroadcastToGame(g.ID, data)
+		broadcastState(g)
+		return nil
+	}
+
+	// Move both players' connections (and any spectators) to the new game
+	// before anyone can find it through game.Latest. The old game's entries
+	// go, since the handlers only ever remove them from the latest game.
+	for _, name := range []string{g.Player1, g.Player2} {
+		if c := manager.Connection(g.ID, name); c != nil {
+			manager.AddConnection(next.ID, name, c)
+			manager.RemoveConnection(g.ID, name, c)
+		}
+	}
+	manager.CopySpectators(g.ID, next.ID)
+	return next
+}
+
+// announceRematch tells both players a rematch has started and starts
+// watching their connections to it
+func announceRematch(g *game.Game) {
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":    "rematch_started",
+		"gameId":  g.ID,
+		"player1": g.Player1,
+		"player2": g.Player2,
+		"series":  game.SeriesScore(g),
+	})
+	manager.BroadcastToGame(g.ID, data)
+	broadcastState(g)
+
+	for _, name := range []string{g.Player1, g.Player2} {
+		if g.BotName != "" && name == game.BotPlayerName {
+			continue
+		}
+		go monitorDisconnection(g, name)
+	}
+	if g.TimeControl.Timed() {
+		go monitorClock(g)
+	}
+}
+
+// gameResult is a finished game's result, copied while the game is locked
+// so it can be saved without holding the lock
+type gameResult struct {
+	g       *game.Game
+	player1 string
+	player2 string
+	winner  int
+	reason  string
+	rated   bool
+}
+
+// endGame copies g's result for saving. Until the result has been saved
+// and announced no rematch can start, so game_over always comes first. The
+// caller must call save once it has unlocked g.
+func endGame(g *game.Game) *gameResult {
+	g.Saving = true
+	return &gameResult{
+		g:       g,
+		player1: g.Player1,
+		player2: g.Player2,
+		winner:  g.Winner,
+		reason:  string(g.EndReason),
+		rated:   g.BotName == "", // Only games between two people are rated
+	}
+}
+
+// save stores the result, announces it and removes the game after a delay.
+// The game must not be locked: saving a rated game is a database
+// transaction.
+func (r *gameResult) save() {
+	ratings, err := db.SaveGameResult(r.player1, r.player2, r.winner, r.reason, r.rated)
 	if err != nil {
 		log.Println("MongoDB save failed:", err)
 	}
-	broadcastGameOver(g)
+
+	r.g.Lock()
+	r.g.Saving = false
+	broadcastGameOver(r.g, ratings)
+	r.g.Unlock()
+
 	// Clean up finished game after a delay (allow time for messages to be sent)
 	go func() {
 		time.Sleep(2 * time.Second)
-		game.RemoveGame(g.ID)
+		game.RemoveGame(r.g.ID)
 	}()
 }
 
-func broadcastState(g *game.Game) {
+// stateMessage is the full state of g sent to players and spectators
+func stateMessage(g *game.Game) []byte {
 	data, _ := json.Marshal(map[string]interface{}{
-		"type":     "state",
-		"board":    g.Board,
-		"turn":     g.Turn,
-		"gameId":   g.ID,
-		"gameOver": g.GameOver,
-		"winner":   g.Winner,
-		"player1":  g.Player1,
-		"player2":  g.Player2,
+		"type":         "state",
+		"board":        g.Board,
+		"rules":        g.Rules,
+		"variant":      game.VariantOf(g).Name(),
+		"powers":       g.Powers,
+		"turn":         g.Turn,
+		"gameId":       g.ID,
+		"gameOver":     g.GameOver,
+		"winner":       g.Winner,
+		"winningLines": g.WinningLines,
+		"player1":      g.Player1,
+		"player2":      g.Player2,
+		"moves":        len(g.History),
+		"takeback":     g.TakebackRequest,
+		"drawOffer":    g.DrawOffer,
+		"rematch":      g.RematchRequest,
+		"spectators":   manager.SpectatorCount(g.ID),
+		"series":       game.SeriesScore(g),
+		"reason":       g.EndReason,
+		"timeControl":  g.TimeControl.String(),
+		"timeLeft":     timeLeft(g),
+		"position":     game.FormatBoard(g.Board, g.Turn),
+		"notation":     gameNotation(g),
 	})
-	manager.BroadcastToGame(g.ID, data)
+	return data
+}
+
+func broadcastState(g *game.Game) {
+	manager.BroadcastToGame(g.ID, stateMessage(g))
 }
 
-func broadcastGameOver(g *game.Game) {
+// broadcastGameOver announces the result, with each player's new rating
+// and change for rated games
+func broadcastGameOver(g *game.Game, ratings map[string]db.RatingChange) {
 	result := "draw"
 	if g.Winner == 1 {
 		result = g.Player1
@@ -245,29 +840,64 @@ func broadcastGameOver(g *game.Game) {
 	}
 
 	data, _ := json.Marshal(map[string]interface{}{
-		"type":   "game_over",
-		"winner": g.Winner,
-		"result": result,
-		"board":  g.Board,
+		"type":         "game_over",
+		"winner":       g.Winner,
+		"result":       result,
+		"board":        g.Board,
+		"reason":       g.EndReason,
+		"winningLines": g.WinningLines,
+		"series":       game.SeriesScore(g),
+		"ratings":      ratings,
 	})
 	manager.BroadcastToGame(g.ID, data)
 }
 
-func sendState(conn *websocket.Conn, g *game.Game) {
-	data, _ := json.Marshal(map[string]interface{}{
-		"type":     "state",
-		"board":    g.Board,
-		"turn":     g.Turn,
-		"gameId":   g.ID,
-		"gameOver": g.GameOver,
-		"winner":   g.Winner,
-		"player1":  g.Player1,
-		"player2":  g.Player2,
-	})
-	conn.WriteMessage(websocket.TextMessage, data)
+func sendState(conn *safeConn, g *game.Game) {
+	conn.WriteMessage(websocket.TextMessage, stateMessage(g))
 }
 
-func sendError(conn *websocket.Conn, msg string) {
+// timeLeft is each player's remaining time in milliseconds, or nil for
+// untimed games
+func timeLeft(g *game.Game) []int64 {
+	if !g.TimeControl.Timed() {
+		return nil
+	}
+	return []int64{
+		game.TimeLeft(g, 1).Milliseconds(),
+		game.TimeLeft(g, 2).Milliseconds(),
+	}
+}
+
+// gameNotation is g's move sequence (e.g. "4453"), or empty if the
+// notation can't describe the game
+func gameNotation(g *game.Game) string {
+	moves, _ := game.GameMoves(g)
+	return moves
+}
+
+// parseRules reads the optional rows, columns and connect query parameters,
+// using the variant's board for any that are missing
+func parseRules(q url.Values, rules game.Rules) (game.Rules, error) {
+	for _, p := range []struct {
+		name  string
+		value *int
+	}{
+		{"rows", &rules.Rows},
+		{"columns", &rules.Columns},
+		{"connect", &rules.Connect},
+	} {
+		if s := q.Get(p.name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return rules, fmt.Errorf("%s must be a number", p.name)
+			}
+			*p.value = n
+		}
+	}
+	return rules, rules.Validate()
+}
+
+func sendError(conn *safeConn, msg string) {
 	data, _ := json.Marshal(map[string]string{
 		"type":  "error",
 		"error": msg,
@@ -275,7 +905,7 @@ func sendError(conn *websocket.Conn, msg string) {
 	conn.WriteMessage(websocket.TextMessage, data)
 }
 
-func sendGameOver(conn *websocket.Conn, g *game.Game) {
+func sendGameOver(conn *safeConn, g *game.Game) {
 	result := "draw"
 	if g.Winner == 1 {
 		result = g.Player1
@@ -284,15 +914,18 @@ func sendGameOver(conn *websocket.Conn, g *game.Game) {
 	}
 
 	data, _ := json.Marshal(map[string]interface{}{
-		"type":   "game_over",
-		"winner": g.Winner,
-		"result": result,
-		"board":  g.Board,
+		"type":         "game_over",
+		"winner":       g.Winner,
+		"result":       result,
+		"board":        g.Board,
+		"reason":       g.EndReason,
+		"winningLines": g.WinningLines,
+		"series":       game.SeriesScore(g),
 	})
 	conn.WriteMessage(websocket.TextMessage, data)
 }
 
-func sendMessage(conn *websocket.Conn, msgType string, data map[string]interface{}) {
+func sendMessage(conn *safeConn, msgType string, data map[string]interface{}) {
 	data["type"] = msgType
 	jsonData, _ := json.Marshal(data)
 	conn.WriteMessage(websocket.TextMessage, jsonData)
@@ -304,29 +937,85 @@ func monitorDisconnection(g *game.Game, username string) {
 	defer ticker.Stop()
 
 	for range ticker.C {
-		if g.GameOver {
+		if checkDisconnection(g, username) {
 			return
 		}
+	}
+}
 
-		// Check if player is still connected
-		if g.Connections != nil && g.Connections[username] {
-			continue
+// checkDisconnection forfeits the game if username has been gone for more
+// than 30 seconds, and reports whether there is nothing left to watch.
+// g must not be locked.
+func checkDisconnection(g *game.Game, username string) bool {
+	g.Lock()
+
+	if g.GameOver {
+		g.Unlock()
+		return true
+	}
+
+	// Check if player is still connected
+	if g.Connections != nil && g.Connections[username] {
+		g.Unlock()
+		return false
+	}
+
+	// Check last seen time
+	if lastSeen, exists := g.LastSeen[username]; exists {
+		if time.Since(lastSeen) > 30*time.Second {
+			log.Printf("Player %s forfeited (disconnected > 30s)", username)
+			game.ForfeitGame(g, username)
+			ended := endGame(g)
+			g.Unlock()
+			// Notify other player if connected
+			notifyForfeit(ended)
+			return true
 		}
+	}
+	g.Unlock()
+	return false
+}
 
-		// Check last seen time
-		if lastSeen, exists := g.LastSeen[username]; exists {
-			if time.Since(lastSeen) > 30*time.Second {
-				log.Printf("Player %s forfeited (disconnected > 30s)", username)
-				game.ForfeitGame(g, username)
-				// Notify other player if connected
-				notifyForfeit(g, username)
-				return
-			}
+// monitorClock ends the game as soon as the player to move runs out of
+// time. Every connection runs one; TimeoutGame lets only the first end it.
+func monitorClock(g *game.Game) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if checkClock(g) {
+			return
 		}
 	}
 }
 
-// handleDisconnect marks player as disconnected
+// checkClock ends g if the player to move has flagged and reports whether
+// the game is over. g must not be locked.
+func checkClock(g *game.Game) bool {
+	g.Lock()
+
+	if g.GameOver {
+		g.Unlock()
+		return true
+	}
+	if !game.TimeoutGame(g) {
+		g.Unlock()
+		return false
+	}
+	loser := g.Player1
+	if g.Winner == 1 {
+		loser = g.Player2
+	}
+	log.Printf("Player %s ran out of time in game %s", loser, g.ID)
+	broadcastState(g)
+	ended := endGame(g)
+	g.Unlock()
+	ended.save()
+	return true
+}
+
+// handleDisconnect marks player as disconnected and drops any pending
+// rematch request, which can't be accepted without them
 func handleDisconnect(g *game.Game, username string) {
 	if g.Connections != nil {
 		g.Connections[username] = false
@@ -336,10 +1025,16 @@ func handleDisconnect(g *game.Game, username string) {
 	}
 	g.LastSeen[username] = time.Now()
 	log.Printf("Player %s disconnected (30s grace period)", username)
+
+	if g.RematchRequest != 0 {
+		g.RematchRequest = 0
+		broadcastState(g)
+	}
 }
 
-// notifyForfeit notifies the opponent about forfeit
-func notifyForfeit(g *game.Game, username string) {
-	// Broadcast game over state to all connected players
-	broadcastGameOver(g)
+// notifyForfeit saves the forfeit and notifies the opponent. The game must
+// not be locked.
+func notifyForfeit(forfeit *gameResult) {
+	// Save, then broadcast game over state to all connected players
+	forfeit.save()
 }
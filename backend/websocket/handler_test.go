@@ -0,0 +1,386 @@
+package websocket
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"connect4/game"
+
+	"github.com/gorilla/websocket"
+)
+
+// testClient is one player talking to the handler the way the frontend does
+type testClient struct {
+	t      *testing.T
+	server string
+	name   string
+	ws     *websocket.Conn
+	gameID string
+	state  map[string]interface{} // last state received
+}
+
+// dial connects name to the server, rejoining gameID if it is set
+func dial(t *testing.T, server, name, gameID string) (*testClient, error) {
+	q := url.Values{"username": {name}}
+	if gameID != "" {
+		q.Set("gameId", gameID)
+	}
+	ws, _, err := websocket.DefaultDialer.Dial(server+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &testClient{t: t, server: server, name: name, ws: ws, gameID: gameID}, nil
+}
+
+// read returns the next message, failing if none arrives in time
+func (c *testClient) read() (map[string]interface{}, error) {
+	c.ws.SetReadDeadline(time.Now().Add(10 * time.Second))
+	var msg map[string]interface{}
+	if err := c.ws.ReadJSON(&msg); err != nil {
+		return nil, fmt.Errorf("%s: %v", c.name, err)
+	}
+	if msg["type"] == "state" {
+		c.state = msg
+		c.gameID, _ = msg["gameId"].(string)
+	}
+	return msg, nil
+}
+
+// readUntil reads messages until one of type msgType arrives
+func (c *testClient) readUntil(msgType string) (map[string]interface{}, error) {
+	for {
+		msg, err := c.read()
+		if err != nil || msg["type"] == msgType {
+			return msg, err
+		}
+	}
+}
+
+// myTurn reports whether the last state says it is c's move
+func (c *testClient) myTurn() bool {
+	s := c.state
+	if s == nil || s["gameOver"] == true {
+		return false
+	}
+	player := "player1"
+	if s["turn"] == float64(2) {
+		player = "player2"
+	}
+	return s[player] == c.name
+}
+
+// play makes random moves until the game is over. If dropAfter is
+// positive, c drops its connection after that many moves and rejoins.
+func (c *testClient) play(rng *rand.Rand, dropAfter int) error {
+	moves := 0
+	for {
+		msg, err := c.read()
+		if err != nil {
+			return err
+		}
+		// A player who rejoins after the end only gets the final state
+		if msg["type"] == "game_over" || msg["type"] == "state" && msg["gameOver"] == true {
+			return nil
+		}
+		switch msg["type"] {
+		case "state", "error":
+			if !c.myTurn() {
+				if dropAfter > 0 && moves >= dropAfter && msg["type"] == "state" {
+					dropAfter = 0
+					c.ws.Close()
+					next, err := dial(c.t, c.server, c.name, c.gameID)
+					if err != nil {
+						return err
+					}
+					c.ws = next.ws
+				}
+				continue
+			}
+			moves++
+			// Full columns come back as errors, which just means try again
+			if err := c.ws.WriteJSON(map[string]interface{}{"type": "move", "column": rng.Intn(7)}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// playMatch plays a game, a rematch with one player dropping out and
+// rejoining, and checks both players end up in the same new game
+func playMatch(c *testClient, seed int64) error {
+	rng := rand.New(rand.NewSource(seed))
+	if err := c.play(rng, 0); err != nil {
+		return err
+	}
+
+	// Player 1 asks, player 2 accepts
+	first := c.state["player1"] == c.name
+	if first {
+		if err := c.ws.WriteJSON(map[string]string{"type": "rematch"}); err != nil {
+			return err
+		}
+	} else {
+		if _, err := c.readUntil("rematch_requested"); err != nil {
+			return err
+		}
+		if err := c.ws.WriteJSON(map[string]string{"type": "rematch_accept"}); err != nil {
+			return err
+		}
+	}
+	old := c.gameID
+	started, err := c.readUntil("rematch_started")
+	if err != nil {
+		return err
+	}
+	if started["gameId"] == old {
+		return fmt.Errorf("%s: rematch kept game ID %s", c.name, old)
+	}
+	if manager.Connection(old, c.name) != nil {
+		return fmt.Errorf("%s: still registered in finished game %s", c.name, old)
+	}
+	dropAfter := 0
+	if first {
+		dropAfter = 3
+	}
+	if err := c.play(rng, dropAfter); err != nil {
+		return err
+	}
+	if c.state["gameId"] != started["gameId"] {
+		return fmt.Errorf("%s: finished game %v, rematch was %v", c.name, c.state["gameId"], started["gameId"])
+	}
+	return nil
+}
+
+// TestConcurrentGames runs several games through the handler at once, with
+// reconnections and rematches, so -race sees every goroutine a game uses
+func TestConcurrentGames(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(HandleWS))
+	defer srv.Close()
+	server := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	const players = 12
+	var wg sync.WaitGroup
+	for i := 0; i < players; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c, err := dial(t, server, fmt.Sprintf("player%d", i), "")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer func() { c.ws.Close() }()
+			if err := playMatch(c, int64(i)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// TestLeaveQueueOnClose checks waiting players are told their place in the
+// queue and leave it when their socket closes
+func TestLeaveQueueOnClose(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(HandleWS))
+	defer srv.Close()
+	server := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	// Different time controls, so nobody is paired
+	var clients []*testClient
+	for i, tc := range []string{"7", "8", "9"} {
+		name := "queued" + tc
+		ws, _, err := websocket.DefaultDialer.Dial(server+"?username="+name+"&time="+tc, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := &testClient{t: t, name: name, ws: ws}
+		defer ws.Close()
+		clients = append(clients, c)
+
+		msg, err := c.readUntil("waiting")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg["position"] != float64(i+1) {
+			t.Errorf("%s: position %v, want %d", name, msg["position"], i+1)
+		}
+	}
+
+	// The first leaves: the others move up
+	clients[0].ws.Close()
+	msg, err := clients[2].readUntil("waiting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg["position"] != float64(2) || msg["queued"] != float64(2) {
+		t.Errorf("after one left: position %v of %v, want 2 of 2", msg["position"], msg["queued"])
+	}
+	if pos, _ := game.QueuePosition("queued7"); pos != 0 {
+		t.Errorf("closed socket still queued at %d", pos)
+	}
+}
+
+// TestRematchAfterLeaving checks a rematch request lapses when the player
+// who asked for it leaves, so the opponent can't pull them back in
+func TestRematchAfterLeaving(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(HandleWS))
+	defer srv.Close()
+	server := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	// A time control nobody else uses, so the two are paired together
+	var players []*testClient
+	for _, name := range []string{"leaver1", "leaver2"} {
+		ws, _, err := websocket.DefaultDialer.Dial(server+"?username="+name+"&time=12", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ws.Close()
+		players = append(players, &testClient{t: t, server: server, name: name, ws: ws})
+	}
+	var wg sync.WaitGroup
+	for i, c := range players {
+		wg.Add(1)
+		go func(c *testClient, seed int64) {
+			defer wg.Done()
+			if err := c.play(rand.New(rand.NewSource(seed)), 0); err != nil {
+				t.Error(err)
+			}
+		}(c, int64(i))
+	}
+	wg.Wait()
+	asker, other := players[0], players[1]
+	old := asker.gameID
+
+	if err := asker.ws.WriteJSON(map[string]string{"type": "rematch"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.readUntil("rematch_requested"); err != nil {
+		t.Fatal(err)
+	}
+	asker.ws.Close()
+	for {
+		state, err := other.readUntil("state")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state["rematch"] == float64(0) {
+			break
+		}
+	}
+
+	if err := other.ws.WriteJSON(map[string]string{"type": "rematch_accept"}); err != nil {
+		t.Fatal(err)
+	}
+	if msg, err := other.readUntil("error"); err != nil {
+		t.Fatal(err)
+	} else if msg["error"] != "No rematch to accept" {
+		t.Errorf("accepting after the asker left: %v", msg["error"])
+	}
+	if g := game.FindGameByUsername(asker.name); g != nil && g.ID != old {
+		t.Errorf("%s moved to game %s", asker.name, g.ID)
+	}
+}
+
+// TestSpectator checks a spectator gets the game's state and broadcasts,
+// is counted in the state, and can't move
+func TestSpectator(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(HandleWS))
+	defer srv.Close()
+	server := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	// A time control nobody else uses, so the two are paired together
+	var players []*testClient
+	for _, name := range []string{"watched1", "watched2"} {
+		ws, _, err := websocket.DefaultDialer.Dial(server+"?username="+name+"&time=11", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ws.Close()
+		c := &testClient{t: t, server: server, name: name, ws: ws}
+		players = append(players, c)
+	}
+	for _, c := range players {
+		if _, err := c.readUntil("state"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ws, _, err := websocket.DefaultDialer.Dial(server+"?spectate=true&gameId="+players[0].gameID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	spectator := &testClient{t: t, name: "spectator", ws: ws}
+	if _, err := spectator.readUntil("spectating"); err != nil {
+		t.Fatal(err)
+	}
+	state, err := spectator.readUntil("state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state["gameId"] != players[0].gameID || state["spectators"] != float64(1) {
+		t.Errorf("spectator state: game %v with %v spectators, want %s with 1", state["gameId"], state["spectators"], players[0].gameID)
+	}
+
+	if err := ws.WriteJSON(map[string]interface{}{"type": "move", "column": 3}); err != nil {
+		t.Fatal(err)
+	}
+	if msg, err := spectator.readUntil("error"); err != nil {
+		t.Fatal(err)
+	} else if msg["error"] != "Spectators can't make moves" {
+		t.Errorf("spectator move: %v", msg["error"])
+	}
+
+	var wg sync.WaitGroup
+	for i, c := range players {
+		wg.Add(1)
+		go func(c *testClient, seed int64) {
+			defer wg.Done()
+			if err := c.play(rand.New(rand.NewSource(seed)), 0); err != nil {
+				t.Error(err)
+			}
+		}(c, int64(i))
+	}
+	wg.Wait()
+	if _, err := spectator.readUntil("game_over"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		query string
+		want  game.Rules // zero if the query is rejected
+	}{
+		{"", game.ClassicRules},
+		{"rows=7&columns=8", game.Rules{Rows: 7, Columns: 8, Connect: 4}},
+		{"columns=9&connect=5", game.Rules{Rows: 6, Columns: 9, Connect: 5}},
+		{"rows=3", game.Rules{}},
+		{"columns=13", game.Rules{}},
+		{"rows=8&columns=8", game.Rules{}},
+		{"connect=8", game.Rules{}},
+		{"connect=2", game.Rules{}},
+		{"rows=-1", game.Rules{}},
+		{"rows=seven", game.Rules{}},
+	}
+	for _, tc := range tests {
+		q, _ := url.ParseQuery(tc.query)
+		got, err := parseRules(q, game.ClassicRules)
+		if tc.want == (game.Rules{}) {
+			if err == nil {
+				t.Errorf("%q: accepted as %s", tc.query, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%q: got %s, %v; want %s", tc.query, got, err, tc.want)
+		}
+	}
+}
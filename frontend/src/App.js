@@ -3,8 +3,34 @@ import Leaderboard from "./Leaderboard";
 
 const WS_URL = "wss://connect4-backend-a4kq.onrender.com/ws";
 
-const emptyBoard = () =>
-  Array.from({ length: 6 }, () => Array(7).fill(0));
+const emptyBoard = (rows = 6, columns = 7) =>
+  Array.from({ length: rows }, () => Array(columns).fill(0));
+
+// Game presets sent as the variant query param, plus rows/columns/connect
+// when the preset changes the variant's board
+const BOARD_PRESETS = {
+  classic: { label: "Classic 6x7, 4 in a row", variant: "classic" },
+  large: { label: "Large 7x8, 4 in a row", variant: "classic", rows: 7, columns: 8, connect: 4 },
+  five: { label: "Five-in-a-Row 6x9", variant: "five" },
+  popout: { label: "PopOut (pop your own bottom discs)", variant: "popout" },
+  powerup: { label: "Power Up (anvil, bomb and double discs)", variant: "powerup" },
+};
+
+// Time controls sent as the time query param (minutes+increment seconds, or
+// seconds per move)
+const TIME_CONTROLS = {
+  "": "Untimed",
+  "1+0": "Bullet 1+0",
+  "3+2": "Blitz 3+2",
+  "10+0": "Rapid 10+0",
+  "30s": "30s per move",
+};
+
+// formatClock shows milliseconds as m:ss
+const formatClock = (ms) => {
+  const secs = Math.ceil(Math.max(ms, 0) / 1000);
+  return `${Math.floor(secs / 60)}:${String(secs % 60).padStart(2, "0")}`;
+};
 
 function App() {
   const [socket, setSocket] = useState(null);
@@ -21,9 +47,35 @@ function App() {
   const [player1, setPlayer1] = useState("");
   const [player2, setPlayer2] = useState("");
   const gameOverRef = useRef(false); // Use ref to track game over state for onclose handler
+  const [spectating, setSpectating] = useState(false); // Watching someone else's game, read-only
+  const spectatingRef = useRef(false); // Same, for the onmessage handler
+  const [spectators, setSpectators] = useState(0); // How many are watching this game
   const [leaderboardRefresh, setLeaderboardRefresh] = useState(0); // Trigger leaderboard refresh
-
-  const connectWebSocket = (user, gameIdParam = "") => {
+  const [difficulty, setDifficulty] = useState("strong"); // Bot strength if the bot joins
+  const [seat, setSeat] = useState("random"); // Move first, second or coin flip against the bot
+  const [boardPreset, setBoardPreset] = useState("classic"); // Board size and win length
+  const [popOut, setPopOut] = useState(false); // Current game allows popping (from the state message)
+  const [powers, setPowers] = useState(null); // Power Up discs left per player (from the state message)
+  const [power, setPower] = useState(""); // Power disc to use on the next move
+  const [practice, setPractice] = useState(false); // Bot grants every takeback
+  const [takeback, setTakeback] = useState(0); // Player number with a pending takeback request
+  const [moves, setMoves] = useState(0); // Moves played so far
+  const [position, setPosition] = useState(""); // Board string, e.g. "7/7/7/7/3o3/2oxx2 x"
+  const [notation, setNotation] = useState(""); // Move sequence, e.g. "4453"
+  const [winningLines, setWinningLines] = useState([]); // Cells of the winner's lines, highlighted
+  const [timeControl, setTimeControl] = useState(""); // Time control to queue for
+  const [drawOffer, setDrawOffer] = useState(0); // Player number with a pending draw offer
+  const [endReason, setEndReason] = useState(""); // How the game ended, e.g. "resignation"
+  const [ratingChange, setRatingChange] = useState(null); // { rating, delta } after a rated game
+  const [roomCode, setRoomCode] = useState(""); // Invite code of the private room you host, or the one to join
+  const [queue, setQueue] = useState(null); // { position, queued } while waiting for an opponent
+  const [rematch, setRematch] = useState(0); // Player number with a pending rematch request
+  const [series, setSeries] = useState(null); // { games, wins: { username: n }, draws } over this game and its rematches
+  const [clock, setClock] = useState(null); // { timeLeft: [ms, ms], at: ms } from the last state message
+  const [, setNow] = useState(Date.now()); // Re-render the running clock
+
+  // room is "new" to create a private room or a friend's invite code to join theirs
+  const connectWebSocket = (user, gameIdParam = "", room = "", spectate = false) => {
     // Reset game state when starting new connection (unless reconnecting to existing game)
     if (!gameIdParam) {
       // New game - reset everything
@@ -37,6 +89,8 @@ function App() {
       setPlayer2("");
     }
     gameOverRef.current = false;
+    spectatingRef.current = spectate;
+    setSpectating(spectate);
     if (socket) {
       socket.close();
     }
@@ -44,6 +98,15 @@ function App() {
     let wsUrl = `${WS_URL}?username=${encodeURIComponent(user)}`;
     if (gameIdParam) {
       wsUrl += `&gameId=${encodeURIComponent(gameIdParam)}`;
+      if (spectate) wsUrl += `&spectate=true`;
+    } else {
+      const preset = BOARD_PRESETS[boardPreset];
+      wsUrl += `&difficulty=${encodeURIComponent(difficulty)}&seat=${encodeURIComponent(seat)}`;
+      wsUrl += `&variant=${preset.variant}`;
+      if (practice) wsUrl += `&practice=true`;
+      if (timeControl) wsUrl += `&time=${encodeURIComponent(timeControl)}`;
+      if (preset.rows) wsUrl += `&rows=${preset.rows}&columns=${preset.columns}&connect=${preset.connect}`;
+      if (room) wsUrl += `&room=${encodeURIComponent(room)}`;
     }
 
     const ws = new WebSocket(wsUrl);
@@ -59,6 +122,7 @@ function App() {
 
       if (data.type === "waiting") {
         setStatus("waiting");
+        setQueue(data.position ? { position: data.position, queued: data.queued } : null);
         setShowUsernameInput(false);
         // Reset board when waiting for opponent (new game)
         setBoard(emptyBoard());
@@ -67,12 +131,28 @@ function App() {
         setWinner(null);
       }
 
+      // Waiting in your own private room for a friend to join with the code
+      if (data.type === "room_created") {
+        setStatus("room");
+        setRoomCode(data.code);
+        setShowUsernameInput(false);
+      }
+
       if (data.type === "game_started") {
         setStatus("playing");
         setOpponent(data.opponent);
         setShowUsernameInput(false);
       }
 
+      // Watching a game - the state message that follows has the board
+      if (data.type === "spectating") {
+        setStatus("spectating");
+        setGameId(data.gameId);
+        setPlayer1(data.player1);
+        setPlayer2(data.player2);
+        setShowUsernameInput(false);
+      }
+
       if (data.type === "reconnected") {
         setStatus("playing");
         setGameId(data.gameId);
@@ -82,6 +162,19 @@ function App() {
 
       if (data.type === "state") {
         setBoard(data.board);
+        setPopOut(!!(data.rules && data.rules.popOut));
+        setPowers(data.powers || null);
+        setTakeback(data.takeback || 0);
+        setDrawOffer(data.drawOffer || 0);
+        setRematch(data.rematch || 0);
+        setSpectators(data.spectators || 0);
+        setSeries(data.series || null);
+        setEndReason(data.reason || "");
+        setMoves(data.moves || 0);
+        setPosition(data.position || "");
+        setNotation(data.notation || "");
+        setWinningLines(data.winningLines || []);
+        setClock(data.timeLeft ? { timeLeft: data.timeLeft, at: Date.now() } : null);
         setTurn(data.turn);
         setGameId(data.gameId || gameId);
         if (data.player1) setPlayer1(data.player1);
@@ -107,6 +200,10 @@ function App() {
 
       if (data.type === "game_over") {
         setGameOver(true);
+        setWinningLines(data.winningLines || []);
+        setEndReason(data.reason || "");
+        if (data.series) setSeries(data.series);
+        setRatingChange((data.ratings && data.ratings[username]) || null);
         gameOverRef.current = true; // Update ref
         setStatus("game_over");
         if (data.winner === 0 || data.result === "draw") {
@@ -118,6 +215,34 @@ function App() {
         setLeaderboardRefresh(prev => prev + 1);
       }
 
+      // Same players, colours swapped - the state message that follows has the new board
+      if (data.type === "rematch_started") {
+        setGameOver(false);
+        gameOverRef.current = false;
+        setWinner(null);
+        setWinningLines([]);
+        setEndReason("");
+        setRematch(0);
+        setRatingChange(null);
+        setStatus(spectatingRef.current ? "spectating" : "playing");
+        setGameId(data.gameId);
+        setPlayer1(data.player1);
+        setPlayer2(data.player2);
+        setSeries(data.series || null);
+      }
+
+      if (data.type === "rematch_declined") {
+        alert(`${data.player} declined the rematch`);
+      }
+
+      if (data.type === "draw_declined") {
+        alert(`${data.player} declined the draw`);
+      }
+
+      if (data.type === "takeback_declined") {
+        alert(`${data.player} declined the takeback`);
+      }
+
       if (data.type === "error") {
         alert(data.error);
       }
@@ -155,6 +280,28 @@ function App() {
     connectWebSocket(username.trim());
   };
 
+  // handleRoom creates a private room, or with a code joins a friend's
+  const handleRoom = (code = "new") => {
+    if (!username.trim()) {
+      alert("Please enter a username");
+      return;
+    }
+    if (!code.trim()) {
+      alert("Please enter the room code");
+      return;
+    }
+    connectWebSocket(username.trim(), "", code.trim());
+  };
+
+  // handleSpectate watches a live game by its ID without playing
+  const handleSpectate = () => {
+    if (!gameId.trim()) {
+      alert("Please enter the game ID");
+      return;
+    }
+    connectWebSocket(username.trim() || "spectator", gameId.trim(), "", true);
+  };
+
   const handleReconnect = () => {
     if (!username.trim() || !gameId.trim()) {
       alert("Please enter username and game ID");
@@ -163,7 +310,54 @@ function App() {
     connectWebSocket(username.trim(), gameId.trim());
   };
 
-  function handleMove(column) {
+  // Tick the clock display while a timed game is running
+  useEffect(() => {
+    if (!clock || gameOver) return;
+    const id = setInterval(() => setNow(Date.now()), 200);
+    return () => clearInterval(id);
+  }, [clock, gameOver]);
+
+  // clockFor is player's time left in ms, counting down on their turn
+  function clockFor(player) {
+    const left = clock.timeLeft[player - 1];
+    return !gameOver && turn === player ? left - (Date.now() - clock.at) : left;
+  }
+
+  // isWinningCell reports whether (row, column) is part of a winning line
+  function isWinningCell(row, column) {
+    return winningLines.some((line) => line.some((cell) => cell.row === row && cell.column === column));
+  }
+
+  // sendAction sends a message with no payload, e.g. resign or offer_draw
+  function sendAction(type) {
+    if (!socket || gameOver || status !== "playing") return;
+    socket.send(JSON.stringify({ type }));
+  }
+
+  // sendRematch sends rematch, rematch_accept or rematch_decline once the game is over
+  function sendRematch(type) {
+    if (!socket || !gameOver || !isConnected) return;
+    socket.send(JSON.stringify({ type }));
+    if (type !== "rematch") setRematch(0);
+  }
+
+  // seriesText is the score over this game and its rematches, e.g. "alice 2 - 1 bob (1 draw)"
+  const seriesText = () => {
+    if (!series || series.games === 0) return "";
+    const wins = (name) => (series.wins && series.wins[name]) || 0;
+    const draws = series.draws ? ` (${series.draws} draw${series.draws === 1 ? "" : "s"})` : "";
+    return `${player1} ${wins(player1)} - ${wins(player2)} ${player2}${draws}`;
+  };
+
+  // sendTakeback sends takeback_request, takeback_accept or takeback_decline
+  function sendTakeback(type) {
+    if (!socket || gameOver || status !== "playing") return;
+    socket.send(JSON.stringify({ type }));
+    if (type !== "takeback_request") setTakeback(0);
+  }
+
+  // handleMove drops a disc, or with pop set removes your own bottom disc (PopOut)
+  function handleMove(column, pop = false) {
     if (!socket || gameOver || status !== "playing") return;
     if (turn === null) return;
 
@@ -185,10 +379,12 @@ function App() {
 
     socket.send(
       JSON.stringify({
-        type: "move",
+        type: pop ? "pop" : "move",
         column,
+        ...(power && !pop ? { power } : {}),
       })
     );
+    setPower("");
   }
 
   function resetGame() {
@@ -203,19 +399,43 @@ function App() {
     setShowUsernameInput(true);
     setPlayer1("");
     setPlayer2("");
+    setRematch(0);
+    setSeries(null);
+    setRatingChange(null);
+    setSpectating(false);
+    spectatingRef.current = false;
+    setSpectators(0);
     if (socket) {
       socket.close();
     }
     setSocket(null);
   }
 
+  // reasonText describes how the game ended, e.g. " (resignation)"
+  const reasonText = () => {
+    const reasons = {
+      connect4: "",
+      board_full: " (board full)",
+      repetition: " (repetition)",
+      resignation: " (resignation)",
+      agreement: " (by agreement)",
+      timeout: " (on time)",
+      abandonment: " (opponent left)",
+    };
+    return reasons[endReason] || "";
+  };
+
   const getStatusMessage = () => {
     if (status === "waiting") {
-      return "Waiting for opponent... Bot will join in 10 seconds if no player found.";
+      const place = queue ? ` (${queue.position} of ${queue.queued} in the queue)` : "";
+      return `Waiting for opponent${place}... Bot will join in 10 seconds if no player found.`;
     }
     if (status === "connecting") {
       return "Connecting...";
     }
+    if (status === "room") {
+      return `Private room ${roomCode} - share this code with a friend. It expires in 5 minutes if nobody joins.`;
+    }
     if (status === "playing") {
       // Determine player number from player1/player2
       let playerNum = 0;
@@ -230,24 +450,31 @@ function App() {
       
       if (gameOver) {
         if (winner === "draw") {
-          return "Game Over - It's a Draw!";
+          return `Game Over - It's a Draw!${reasonText()}`;
         }
         const winnerName = winner === 1 ? player1 : player2;
         const isYou = (winner === playerNum);
-        return `Game Over - ${winnerName}${isYou ? " (You)" : ""} wins!`;
+        return `Game Over - ${winnerName}${isYou ? " (You)" : ""} wins!${reasonText()}`;
       }
       
       const currentPlayerName = turn === 1 ? player1 : player2;
       const isYourTurn = (turn === playerNum);
       return `Turn: ${currentPlayerName}${isYourTurn ? " (You)" : ""}`;
     }
+    if (status === "spectating") {
+      const currentPlayerName = turn === 1 ? player1 : player2;
+      return `Watching ${player1} vs ${player2}${currentPlayerName ? ` - Turn: ${currentPlayerName}` : ""}`;
+    }
     if (status === "game_over") {
       if (winner === "draw") {
-        return "Game Over - It's a Draw!";
+        return `Game Over - It's a Draw!${reasonText()}`;
       }
-      return `Game Over - Player ${winner} wins!`;
+      return `Game Over - Player ${winner} wins!${reasonText()}`;
     }
     if (status === "disconnected") {
+      if (spectating) {
+        return "Disconnected. You can watch again using the game ID.";
+      }
       return "Disconnected. You can reconnect within 30 seconds using your game ID.";
     }
     return "";
@@ -268,11 +495,78 @@ function App() {
             style={{ padding: 8, marginRight: 10, width: 200 }}
             onKeyPress={(e) => e.key === "Enter" && handleStartGame()}
           />
+          <select
+            value={difficulty}
+            onChange={(e) => setDifficulty(e.target.value)}
+            style={{ padding: 8, marginRight: 10 }}
+            title="Bot difficulty (used if no player is found)"
+          >
+            <option value="">Match my rating</option>
+            <option value="beginner">Beginner</option>
+            <option value="casual">Casual</option>
+            <option value="strong">Strong</option>
+            <option value="expert">Expert</option>
+            <option value="mcts">MCTS (unpredictable)</option>
+          </select>
+          <select
+            value={seat}
+            onChange={(e) => setSeat(e.target.value)}
+            style={{ padding: 8, marginRight: 10 }}
+            title="Turn order against the bot"
+          >
+            <option value="random">Coin flip</option>
+            <option value="first">Move first</option>
+            <option value="second">Move second</option>
+          </select>
+          <select
+            value={boardPreset}
+            onChange={(e) => setBoardPreset(e.target.value)}
+            style={{ padding: 8, marginRight: 10 }}
+            title="Board size and win length"
+          >
+            {Object.entries(BOARD_PRESETS).map(([value, preset]) => (
+              <option key={value} value={value}>{preset.label}</option>
+            ))}
+          </select>
+          <select
+            value={timeControl}
+            onChange={(e) => setTimeControl(e.target.value)}
+            style={{ padding: 8, marginRight: 10 }}
+            title="Time control"
+          >
+            {Object.entries(TIME_CONTROLS).map(([value, label]) => (
+              <option key={value} value={value}>{label}</option>
+            ))}
+          </select>
+          <label style={{ marginRight: 10 }} title="Against the bot, every takeback is granted">
+            <input
+              type="checkbox"
+              checked={practice}
+              onChange={(e) => setPractice(e.target.checked)}
+            />{" "}
+            Practice
+          </label>
           <button onClick={handleStartGame} style={{ padding: 8, marginRight: 10 }}>
             Start New Game
           </button>
+          <button onClick={() => handleRoom()} style={{ padding: 8, marginRight: 10 }} title="Play a friend: get a code to share">
+            Create Private Room
+          </button>
           <div style={{ marginTop: 10 }}>
-            <h4>Reconnect to existing game:</h4>
+            <h4>Join a friend's private room:</h4>
+            <input
+              type="text"
+              value={roomCode}
+              onChange={(e) => setRoomCode(e.target.value.toUpperCase())}
+              placeholder="Enter room code"
+              style={{ padding: 8, marginRight: 10, width: 200 }}
+            />
+            <button onClick={() => handleRoom(roomCode)} style={{ padding: 8 }}>
+              Join Room
+            </button>
+          </div>
+          <div style={{ marginTop: 10 }}>
+            <h4>Reconnect to or watch an existing game:</h4>
             <input
               type="text"
               value={gameId}
@@ -280,9 +574,12 @@ function App() {
               placeholder="Enter game ID"
               style={{ padding: 8, marginRight: 10, width: 200 }}
             />
-            <button onClick={handleReconnect} style={{ padding: 8 }}>
+            <button onClick={handleReconnect} style={{ padding: 8, marginRight: 10 }}>
               Reconnect
             </button>
+            <button onClick={handleSpectate} style={{ padding: 8 }} title="Watch this game without playing">
+              Spectate
+            </button>
           </div>
         </div>
       )}
@@ -292,7 +589,18 @@ function App() {
           <strong>Status:</strong> {getStatusMessage()}
           {gameId && (
             <div style={{ marginTop: 5, fontSize: 12, color: "#666" }}>
-              Game ID: {gameId} (Save this to reconnect)
+              Game ID: {gameId} {spectating ? "(Share this to invite more spectators)" : "(Save this to reconnect)"}
+            </div>
+          )}
+          {spectators > 0 && (
+            <div style={{ marginTop: 5, fontSize: 12, color: "#666" }}>
+              👀 {spectators} watching
+            </div>
+          )}
+          {position && (
+            <div style={{ marginTop: 5, fontSize: 12, color: "#666", fontFamily: "monospace" }}>
+              Position: {position}
+              {notation && <> | Moves: {notation}</>}
             </div>
           )}
         </div>
@@ -304,10 +612,34 @@ function App() {
         </div>
       )}
 
+      {seriesText() && (
+        <div style={{ marginBottom: 10 }}>
+          <strong>Series:</strong> {seriesText()}
+        </div>
+      )}
+
+      {gameOver && ratingChange && (
+        <div style={{ marginBottom: 10 }}>
+          <strong>Rating:</strong> {Math.round(ratingChange.rating)} ({ratingChange.delta >= 0 ? "+" : ""}
+          {Math.round(ratingChange.delta)})
+        </div>
+      )}
+
+      {clock && (
+        <div style={{ marginBottom: 10, fontFamily: "monospace", fontSize: 18 }}>
+          <span style={{ fontWeight: turn === 1 ? "bold" : "normal", marginRight: 20 }}>
+            🔴 {player1}: {formatClock(clockFor(1))}
+          </span>
+          <span style={{ fontWeight: turn === 2 ? "bold" : "normal" }}>
+            🟡 {player2}: {formatClock(clockFor(2))}
+          </span>
+        </div>
+      )}
+
       <div style={{ display: "flex", flexDirection: "column", gap: 5, marginBottom: 20, alignItems: "center" }}>
         {/* Column headers - clickable to drop discs */}
         <div style={{ display: "flex", gap: 5, marginBottom: 5 }}>
-          {Array.from({ length: 7 }).map((_, c) => (
+          {Array.from({ length: board[0].length }).map((_, c) => (
             <div
               key={`header-${c}`}
               onClick={() => handleMove(c)}
@@ -341,15 +673,134 @@ function App() {
                   borderRadius: "50%",
                   background:
                     cell === 1 ? "red" : cell === 2 ? "yellow" : "#eee",
-                  border: "2px solid #333",
+                  border: isWinningCell(r, c) ? "4px solid #0a0" : "2px solid #333",
                   opacity: cell === 0 ? 0.3 : 1,
                 }}
               />
             ))}
           </div>
         ))}
+        {/* Power Up - pick a special disc for the next move */}
+        {powers && (
+          <select
+            value={power}
+            onChange={(e) => setPower(e.target.value)}
+            style={{ padding: 8, marginTop: 5 }}
+            title="Power disc for your next move"
+          >
+            <option value="">Normal disc</option>
+            {(powers[username === player2 ? 2 : 1] || []).map((p) => (
+              <option key={p} value={p}>{p}</option>
+            ))}
+          </select>
+        )}
+        {/* Pop buttons - remove your own bottom disc in PopOut games */}
+        {popOut && (
+          <div style={{ display: "flex", gap: 5, marginTop: 5 }}>
+            {Array.from({ length: board[0].length }).map((_, c) => (
+              <div
+                key={`pop-${c}`}
+                onClick={() => handleMove(c, true)}
+                title="Pop your bottom disc"
+                style={{
+                  width: 50,
+                  height: 30,
+                  background: "#ddd",
+                  border: "2px solid #333",
+                  borderRadius: 5,
+                  cursor: gameOver || status !== "playing" ? "not-allowed" : "pointer",
+                  display: "flex",
+                  alignItems: "center",
+                  justifyContent: "center",
+                  fontSize: 12,
+                  fontWeight: "bold",
+                }}
+              >
+                ↑
+              </div>
+            ))}
+          </div>
+        )}
       </div>
 
+      {/* Resign and draw offers */}
+      {status === "playing" && !gameOver && (
+        <div style={{ marginBottom: 10 }}>
+          <button onClick={() => window.confirm("Resign this game?") && sendAction("resign")} style={{ padding: 8, marginRight: 5 }}>
+            Resign
+          </button>
+          {drawOffer === 0 && (
+            <button onClick={() => sendAction("offer_draw")} style={{ padding: 8 }}>
+              Offer Draw
+            </button>
+          )}
+          {drawOffer !== 0 && drawOffer === (username === player2 ? 2 : 1) && (
+            <span>Draw offered, waiting for {opponent}...</span>
+          )}
+          {drawOffer !== 0 && drawOffer !== (username === player2 ? 2 : 1) && (
+            <span>
+              {opponent} offers a draw{" "}
+              <button onClick={() => sendAction("accept_draw")} style={{ padding: 8, marginRight: 5 }}>
+                Accept
+              </button>
+              <button onClick={() => sendAction("decline_draw")} style={{ padding: 8 }}>
+                Decline
+              </button>
+            </span>
+          )}
+        </div>
+      )}
+
+      {/* Takebacks - ask to undo your last move, or answer the opponent */}
+      {status === "playing" && !gameOver && moves > 0 && (
+        <div style={{ marginBottom: 20 }}>
+          {takeback === 0 && (
+            <button onClick={() => sendTakeback("takeback_request")} style={{ padding: 8 }}>
+              Request Takeback
+            </button>
+          )}
+          {takeback !== 0 && takeback === (username === player2 ? 2 : 1) && (
+            <span>Waiting for {opponent} to answer your takeback request...</span>
+          )}
+          {takeback !== 0 && takeback !== (username === player2 ? 2 : 1) && (
+            <span>
+              {opponent} asks to take back their move{" "}
+              <button onClick={() => sendTakeback("takeback_accept")} style={{ padding: 8, marginRight: 5 }}>
+                Accept
+              </button>
+              <button onClick={() => sendTakeback("takeback_decline")} style={{ padding: 8 }}>
+                Decline
+              </button>
+            </span>
+          )}
+        </div>
+      )}
+
+      {/* Rematch - play the same opponent again with colours swapped */}
+      {gameOver && isConnected && !spectating && (
+        <div style={{ marginBottom: 10 }}>
+          {rematch === 0 && (
+            <button onClick={() => sendRematch("rematch")} style={{ padding: 10, fontSize: 16, marginRight: 5 }}>
+              Rematch
+            </button>
+          )}
+          {rematch !== 0 && rematch === (username === player2 ? 2 : 1) && (
+            <span>Waiting for {opponent} to accept the rematch...</span>
+          )}
+          {rematch !== 0 && rematch !== (username === player2 ? 2 : 1) && (
+            <span>
+              {opponent} wants a rematch{" "}
+              <button onClick={() => sendRematch("rematch_accept")} style={{ padding: 8, marginRight: 5 }}>
+                Accept
+              </button>
+              <button onClick={() => sendRematch("rematch_decline")} style={{ padding: 8 }}>
+                Decline
+              </button>
+            </span>
+          )}
+        </div>
+      )}
+
       {gameOver && (
         <button onClick={resetGame} style={{ padding: 10, fontSize: 16, marginBottom: 20 }}>
           New Game
@@ -56,7 +56,7 @@ function Leaderboard({ refreshTrigger = 0 }) {
 
       {!loading && !error && data.length === 0 && (
         <p style={{ color: "#999", fontStyle: "italic" }}>
-          No games played yet.
+          No rated players yet (5 games against other players needed).
         </p>
       )}
 
@@ -64,7 +64,7 @@ function Leaderboard({ refreshTrigger = 0 }) {
         <ul>
           {data.map((item, index) => (
             <li key={index}>
-              {item.player} — {item.wins} wins
+              {item.player} — {Math.round(item.rating)} ({item.wins}W {item.losses}L {item.draws}D)
             </li>
           ))}
         </ul>